@@ -1,15 +1,65 @@
 package aws
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sts"
 
 	"github.com/redbubble/yak/saml"
 )
 
-func AssumeRole(login saml.LoginData, role saml.LoginRole, duration int64) (*sts.AssumeRoleWithSAMLOutput, error) {
-	session := session.Must(session.NewSession())
-	stsClient := sts.New(session)
+type StsConfig struct {
+	Region   string
+	Endpoint string
+}
+
+type ChainedRole struct {
+	RoleArn           string
+	ExternalId        string
+	SessionName       string
+	SessionTags       map[string]string
+	TransitiveTagKeys []string
+	SourceIdentity    string
+	SerialNumber      string
+	TokenCode         string
+}
+
+// AWS won't hand out chained sessions longer than this, whatever the role's
+// maximum session duration is
+const MaxChainedSessionDuration int64 = 3600
+
+const (
+	maxSessionTags           = 50
+	maxSessionTagKeyLength   = 128
+	maxSessionTagValueLength = 256
+)
+
+var sessionNamePattern = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+
+var sessionNameInvalidCharacters = regexp.MustCompile(`[^\w+=,.@-]`)
+
+var partitionDefaultRegions = map[string]string{
+	endpoints.AwsPartitionID:      endpoints.UsEast1RegionID,
+	endpoints.AwsCnPartitionID:    endpoints.CnNorth1RegionID,
+	endpoints.AwsUsGovPartitionID: endpoints.UsGovWest1RegionID,
+	endpoints.AwsIsoPartitionID:   endpoints.UsIsoEast1RegionID,
+	endpoints.AwsIsoBPartitionID:  endpoints.UsIsobEast1RegionID,
+}
+
+func AssumeRole(login saml.LoginData, role saml.LoginRole, duration int64, config StsConfig) (*sts.AssumeRoleWithSAMLOutput, error) {
+	stsClient, err := newStsClient(role.RoleArn, config, nil)
+
+	if err != nil {
+		return nil, err
+	}
 
 	input := sts.AssumeRoleWithSAMLInput{
 		DurationSeconds: &duration,
@@ -21,6 +71,196 @@ func AssumeRole(login saml.LoginData, role saml.LoginRole, duration int64) (*sts
 	return stsClient.AssumeRoleWithSAML(&input)
 }
 
+func ChainRole(source *sts.AssumeRoleWithSAMLOutput, role ChainedRole, duration int64, config StsConfig) (*sts.AssumeRoleWithSAMLOutput, error) {
+	sourceCredentials := credentials.NewStaticCredentials(
+		*source.Credentials.AccessKeyId,
+		*source.Credentials.SecretAccessKey,
+		*source.Credentials.SessionToken,
+	)
+
+	stsClient, err := newStsClient(role.RoleArn, config, sourceCredentials)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if duration > MaxChainedSessionDuration {
+		duration = MaxChainedSessionDuration
+	}
+
+	input := sts.AssumeRoleInput{
+		DurationSeconds: &duration,
+		RoleArn:         &role.RoleArn,
+		RoleSessionName: &role.SessionName,
+	}
+
+	if role.ExternalId != "" {
+		input.ExternalId = &role.ExternalId
+	}
+
+	if role.SerialNumber != "" {
+		input.SerialNumber = &role.SerialNumber
+		input.TokenCode = &role.TokenCode
+	}
+
+	if role.SourceIdentity != "" {
+		input.SourceIdentity = &role.SourceIdentity
+	}
+
+	for key, value := range role.SessionTags {
+		input.Tags = append(input.Tags, &sts.Tag{Key: awssdk.String(key), Value: awssdk.String(value)})
+	}
+
+	if len(role.TransitiveTagKeys) > 0 {
+		input.TransitiveTagKeys = awssdk.StringSlice(role.TransitiveTagKeys)
+	}
+
+	output, err := stsClient.AssumeRole(&input)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything downstream deals in the SAML flavour of output, which has all
+	// the same fields we care about
+	return &sts.AssumeRoleWithSAMLOutput{
+		AssumedRoleUser:  output.AssumedRoleUser,
+		Credentials:      output.Credentials,
+		PackedPolicySize: output.PackedPolicySize,
+		SourceIdentity:   output.SourceIdentity,
+	}, nil
+}
+
+// CleanSessionName makes a session name STS will accept out of something
+// like a username, e.g. DOMAIN\user becomes DOMAIN-user. It returns an empty
+// name if there's too little left to use.
+func CleanSessionName(name string) string {
+	cleaned := sessionNameInvalidCharacters.ReplaceAllString(name, "-")
+
+	if len(cleaned) > 64 {
+		cleaned = cleaned[:64]
+	}
+
+	if len(cleaned) < 2 {
+		return ""
+	}
+
+	return cleaned
+}
+
+// Validate checks the things STS would otherwise reject, so we can give a
+// clearer error before prompting for anything
+func (role ChainedRole) Validate() error {
+	if role.SessionName != "" && !sessionNamePattern.MatchString(role.SessionName) {
+		return fmt.Errorf("Session name '%s' must be 2-64 characters of letters, digits and +=,.@-", role.SessionName)
+	}
+
+	if role.SourceIdentity != "" && !sessionNamePattern.MatchString(role.SourceIdentity) {
+		return fmt.Errorf("Source identity '%s' must be 2-64 characters of letters, digits and +=,.@-", role.SourceIdentity)
+	}
+
+	if len(role.SessionTags) > maxSessionTags {
+		return fmt.Errorf("%d session tags requested, but AWS allows at most %d", len(role.SessionTags), maxSessionTags)
+	}
+
+	for key, value := range role.SessionTags {
+		if len(key) == 0 || len(key) > maxSessionTagKeyLength {
+			return fmt.Errorf("Session tag key '%s' must be 1-%d characters", key, maxSessionTagKeyLength)
+		}
+
+		if len(value) > maxSessionTagValueLength {
+			return fmt.Errorf("Value of session tag '%s' must be at most %d characters", key, maxSessionTagValueLength)
+		}
+	}
+
+	for _, key := range role.TransitiveTagKeys {
+		if _, ok := role.SessionTags[key]; !ok {
+			return fmt.Errorf("Transitive tag key '%s' is not one of the session tags", key)
+		}
+	}
+
+	return nil
+}
+
+// SessionName pulls the session name out of an assumed role ARN, e.g.
+// arn:aws:sts::123456789012:assumed-role/<role>/<session name>
+func SessionName(stsOutput *sts.AssumeRoleWithSAMLOutput) string {
+	if stsOutput.AssumedRoleUser == nil || stsOutput.AssumedRoleUser.Arn == nil {
+		return ""
+	}
+
+	parsed, err := arn.Parse(*stsOutput.AssumedRoleUser.Arn)
+
+	if err != nil {
+		return ""
+	}
+
+	parts := strings.Split(parsed.Resource, "/")
+
+	return parts[len(parts)-1]
+}
+
+func newStsClient(roleArn string, config StsConfig, creds *credentials.Credentials) (*sts.STS, error) {
+	region, err := StsRegion(roleArn, config.Region)
+
+	if err != nil {
+		return nil, err
+	}
+
+	awsConfig := awssdk.NewConfig().WithRegion(region)
+
+	if creds != nil {
+		awsConfig = awsConfig.WithCredentials(creds)
+	}
+
+	// Without this, the SDK quietly sends requests for most commercial
+	// regions to the global endpoint in us-east-1
+	if config.Region != "" {
+		awsConfig = awsConfig.WithSTSRegionalEndpoint(endpoints.RegionalSTSEndpoint)
+	}
+
+	if config.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(config.Endpoint)
+	}
+
+	session, err := session.NewSession(awsConfig)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sts.New(session), nil
+}
+
+// StsRegion works out which region's STS to talk to for a role. STS only
+// accepts SAML assertions for providers in its own partition, so anything
+// configured has to agree with the role's ARN.
+func StsRegion(roleArn string, configuredRegion string) (string, error) {
+	parsed, err := arn.Parse(roleArn)
+
+	if err != nil {
+		return "", err
+	}
+
+	if configuredRegion != "" {
+		partition, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), configuredRegion)
+
+		if ok && partition.ID() != parsed.Partition {
+			return "", fmt.Errorf("STS region %s is in partition %s, but role %s is in partition %s", configuredRegion, partition.ID(), roleArn, parsed.Partition)
+		}
+
+		return configuredRegion, nil
+	}
+
+	region, ok := partitionDefaultRegions[parsed.Partition]
+
+	if !ok {
+		return "", fmt.Errorf("Unknown AWS partition '%s' for role %s; please configure aws.sts_region", parsed.Partition, roleArn)
+	}
+
+	return region, nil
+}
+
 func EnvironmentVariables(stsOutput *sts.AssumeRoleWithSAMLOutput) map[string]string {
 	subject := make(map[string]string)
 
@@ -29,5 +269,20 @@ func EnvironmentVariables(stsOutput *sts.AssumeRoleWithSAMLOutput) map[string]st
 	subject["AWS_SESSION_TOKEN"] = *stsOutput.Credentials.SessionToken
 	subject["AWS_METADATA_USER_ARN"] = *stsOutput.AssumedRoleUser.Arn
 
+	if stsOutput.Credentials.Expiration != nil {
+		subject["AWS_SESSION_EXPIRATION"] = stsOutput.Credentials.Expiration.UTC().Format(time.RFC3339)
+	}
+
+	return subject
+}
+
+func RegionEnvironmentVariables(region string) map[string]string {
+	subject := make(map[string]string)
+
+	if region != "" {
+		subject["AWS_REGION"] = region
+		subject["AWS_DEFAULT_REGION"] = region
+	}
+
 	return subject
 }
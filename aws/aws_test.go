@@ -1,8 +1,12 @@
 package aws
 
 import (
-	"github.com/aws/aws-sdk-go/service/sts"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
 )
 
 func TestEnvironmentVariables(t *testing.T) {
@@ -55,4 +59,139 @@ func TestEnvironmentVariables(t *testing.T) {
 		t.Logf("Got: %s", subject["AWS_METADATA_USER_ARN"])
 		t.Fail()
 	}
+
+	if _, ok := subject["AWS_SESSION_EXPIRATION"]; ok {
+		t.Log("---------------")
+		t.Log("Set AWS_SESSION_EXPIRATION without an expiration")
+		t.Fail()
+	}
+
+	expiration := time.Date(2018, 4, 4, 23, 59, 54, 0, time.FixedZone("AEST", 10*60*60))
+	creds.Credentials.Expiration = &expiration
+
+	subject = EnvironmentVariables(&creds)
+
+	if subject["AWS_SESSION_EXPIRATION"] != "2018-04-04T13:59:54Z" {
+		t.Log("---------------")
+		t.Log("Did not correctly set AWS_SESSION_EXPIRATION")
+		t.Logf("Expected: %s", "2018-04-04T13:59:54Z")
+		t.Logf("Got: %s", subject["AWS_SESSION_EXPIRATION"])
+		t.Fail()
+	}
+}
+
+func TestStsRegion(t *testing.T) {
+	scenarios := []struct {
+		roleArn          string
+		configuredRegion string
+		expectedRegion   string
+	}{
+		{"arn:aws:iam::123456789012:role/llama", "", "us-east-1"},
+		{"arn:aws-us-gov:iam::123456789012:role/llama", "", "us-gov-west-1"},
+		{"arn:aws-cn:iam::123456789012:role/llama", "", "cn-north-1"},
+		{"arn:aws:iam::123456789012:role/llama", "ap-southeast-2", "ap-southeast-2"},
+		{"arn:aws-us-gov:iam::123456789012:role/llama", "us-gov-east-1", "us-gov-east-1"},
+	}
+
+	for _, scenario := range scenarios {
+		region, err := StsRegion(scenario.roleArn, scenario.configuredRegion)
+
+		if err != nil || region != scenario.expectedRegion {
+			t.Log("---------------")
+			t.Logf("Did not pick the right STS region for %s", scenario.roleArn)
+			t.Logf("Expected: %s", scenario.expectedRegion)
+			t.Logf("Got: %s (error: %v)", region, err)
+			t.Fail()
+		}
+	}
+
+	if _, err := StsRegion("arn:aws-us-gov:iam::123456789012:role/llama", "us-east-1"); err == nil {
+		t.Log("---------------")
+		t.Log("Allowed an STS region outside the role's partition")
+		t.Fail()
+	}
+
+	if _, err := StsRegion("arn:aws-mars:iam::123456789012:role/llama", ""); err == nil {
+		t.Log("---------------")
+		t.Log("Picked an STS region for an unknown partition")
+		t.Fail()
+	}
+}
+
+func TestRegionEnvironmentVariables(t *testing.T) {
+	subject := RegionEnvironmentVariables("ap-southeast-2")
+
+	if subject["AWS_REGION"] != "ap-southeast-2" || subject["AWS_DEFAULT_REGION"] != "ap-southeast-2" {
+		t.Log("---------------")
+		t.Log("Did not correctly set AWS_REGION and AWS_DEFAULT_REGION")
+		t.Logf("Got: %v", subject)
+		t.Fail()
+	}
+
+	if len(RegionEnvironmentVariables("")) != 0 {
+		t.Log("---------------")
+		t.Log("Set region variables when no region was configured")
+		t.Fail()
+	}
+}
+
+func TestChainedRoleValidate(t *testing.T) {
+	valid := ChainedRole{
+		RoleArn:           "arn:aws:iam::123456789012:role/llama",
+		SessionName:       "guanaco@example.com",
+		SessionTags:       map[string]string{"Team": "camelids", "CostCentre": ""},
+		TransitiveTagKeys: []string{"Team"},
+		SourceIdentity:    "alpaca@example.com",
+	}
+
+	if err := valid.Validate(); err != nil {
+		t.Log("---------------")
+		t.Log("Rejected a valid chained role")
+		t.Logf("Error: %v", err)
+		t.Fail()
+	}
+
+	tooManyTags := map[string]string{}
+	for i := 0; i <= maxSessionTags; i++ {
+		tooManyTags[fmt.Sprintf("tag%d", i)] = "llama"
+	}
+
+	invalid := map[string]ChainedRole{
+		"short session name":         {SessionName: "l"},
+		"bad session name":           {SessionName: "llama alpaca"},
+		"bad source identity":        {SourceIdentity: "llama/alpaca"},
+		"too many tags":              {SessionTags: tooManyTags},
+		"long tag key":               {SessionTags: map[string]string{strings.Repeat("l", 129): "llama"}},
+		"long tag value":             {SessionTags: map[string]string{"llama": strings.Repeat("l", 257)}},
+		"unknown transitive tag key": {SessionTags: map[string]string{"llama": "alpaca"}, TransitiveTagKeys: []string{"vicuña"}},
+	}
+
+	for name, role := range invalid {
+		if err := role.Validate(); err == nil {
+			t.Log("---------------")
+			t.Logf("Accepted a chained role with a %s", name)
+			t.Fail()
+		}
+	}
+}
+
+func TestCleanSessionName(t *testing.T) {
+	scenarios := map[string]string{
+		"guanaco@example.com":    "guanaco@example.com",
+		"CAMELIDS\\llama":        "CAMELIDS-llama",
+		"vicuña alpaca":          "vicu-a-alpaca",
+		strings.Repeat("l", 100): strings.Repeat("l", 64),
+		"l":                      "",
+		"":                       "",
+	}
+
+	for name, expected := range scenarios {
+		if cleaned := CleanSessionName(name); cleaned != expected {
+			t.Log("---------------")
+			t.Logf("Did not clean up the session name '%s'", name)
+			t.Logf("Expected: %s", expected)
+			t.Logf("Got: %s", cleaned)
+			t.Fail()
+		}
+	}
 }
@@ -0,0 +1,161 @@
+package aws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+type ConsoleOptions struct {
+	FederationEndpoint string
+	Service            string
+	Region             string
+	SessionDuration    int64
+	Issuer             string
+}
+
+type consoleDomains struct {
+	Signin  string
+	Console string
+}
+
+var partitionConsoleDomains = map[string]consoleDomains{
+	endpoints.AwsPartitionID:      {"signin.aws.amazon.com", "console.aws.amazon.com"},
+	endpoints.AwsCnPartitionID:    {"signin.amazonaws.cn", "console.amazonaws.cn"},
+	endpoints.AwsUsGovPartitionID: {"signin.amazonaws-us-gov.com", "console.amazonaws-us-gov.com"},
+}
+
+type signinTokenResponse struct {
+	SigninToken string
+}
+
+func ConsoleSigninUrl(stsOutput *sts.AssumeRoleWithSAMLOutput, options ConsoleOptions) (string, error) {
+	domains, err := consoleDomainsFor(stsOutput)
+
+	if err != nil {
+		return "", err
+	}
+
+	federationEndpoint := options.FederationEndpoint
+	if federationEndpoint == "" {
+		federationEndpoint = "https://" + domains.Signin + "/federation"
+	}
+
+	token, err := getSigninToken(federationEndpoint, stsOutput, options.SessionDuration)
+
+	if err != nil {
+		return "", err
+	}
+
+	loginUrl, err := url.Parse(federationEndpoint)
+
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("Action", "login")
+	query.Set("Issuer", options.Issuer)
+	query.Set("Destination", consoleDestination(domains.Console, options.Service, options.Region))
+	query.Set("SigninToken", token)
+	loginUrl.RawQuery = query.Encode()
+
+	return loginUrl.String(), nil
+}
+
+func consoleDomainsFor(stsOutput *sts.AssumeRoleWithSAMLOutput) (consoleDomains, error) {
+	parsed, err := arn.Parse(*stsOutput.AssumedRoleUser.Arn)
+
+	if err != nil {
+		return consoleDomains{}, err
+	}
+
+	domains, ok := partitionConsoleDomains[parsed.Partition]
+
+	if !ok {
+		return consoleDomains{}, fmt.Errorf("The AWS console isn't available for partition '%s'", parsed.Partition)
+	}
+
+	return domains, nil
+}
+
+func consoleDestination(consoleDomain string, service string, region string) string {
+	destination := url.URL{Scheme: "https", Host: consoleDomain, Path: "/"}
+
+	if service != "" {
+		destination.Path = "/" + service + "/home"
+	} else if region != "" {
+		destination.Path = "/console/home"
+	}
+
+	if region != "" {
+		destination.RawQuery = url.Values{"region": {region}}.Encode()
+	}
+
+	return destination.String()
+}
+
+func getSigninToken(federationEndpoint string, stsOutput *sts.AssumeRoleWithSAMLOutput, sessionDuration int64) (string, error) {
+	session, err := json.Marshal(map[string]string{
+		"sessionId":    *stsOutput.Credentials.AccessKeyId,
+		"sessionKey":   *stsOutput.Credentials.SecretAccessKey,
+		"sessionToken": *stsOutput.Credentials.SessionToken,
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	tokenUrl, err := url.Parse(federationEndpoint)
+
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("Action", "getSigninToken")
+	query.Set("Session", string(session))
+
+	if sessionDuration > 0 {
+		query.Set("SessionDuration", strconv.FormatInt(sessionDuration, 10))
+	}
+
+	tokenUrl.RawQuery = query.Encode()
+
+	resp, err := http.Get(tokenUrl.String())
+
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return "", errors.New("Could not get console sign-in token (" + resp.Status + ")")
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return "", err
+	}
+
+	response := signinTokenResponse{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return "", err
+	}
+
+	if response.SigninToken == "" {
+		return "", errors.New("No sign-in token in response from the federation endpoint")
+	}
+
+	return response.SigninToken, nil
+}
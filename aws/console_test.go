@@ -0,0 +1,68 @@
+package aws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+func TestConsoleSigninUrl(t *testing.T) {
+	accessKeyId := "llama"
+	secretAccessKey := "alpaca"
+	sessionToken := "guanaco"
+	assumedRoleArn := "arn:aws:sts::123456789012:assumed-role/vicuña/me"
+
+	creds := sts.AssumeRoleWithSAMLOutput{
+		AssumedRoleUser: &sts.AssumedRoleUser{Arn: &assumedRoleArn},
+		Credentials: &sts.Credentials{
+			AccessKeyId:     &accessKeyId,
+			SecretAccessKey: &secretAccessKey,
+			SessionToken:    &sessionToken,
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := map[string]string{}
+		json.Unmarshal([]byte(r.URL.Query().Get("Session")), &session)
+
+		if r.URL.Query().Get("Action") != "getSigninToken" || r.URL.Query().Get("SessionDuration") != "1800" ||
+			session["sessionId"] != accessKeyId || session["sessionKey"] != secretAccessKey || session["sessionToken"] != sessionToken {
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		w.Write([]byte(`{"SigninToken":"camelid"}`))
+	}))
+	defer server.Close()
+
+	subject, err := ConsoleSigninUrl(&creds, ConsoleOptions{
+		FederationEndpoint: server.URL + "/federation",
+		Service:            "ec2",
+		Region:             "ap-southeast-2",
+		SessionDuration:    1800,
+		Issuer:             "yak",
+	})
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("Failed to create a console sign-in URL")
+		t.Logf("Error: %v", err)
+		t.FailNow()
+	}
+
+	parsed, _ := url.Parse(subject)
+	expectedDestination := "https://console.aws.amazon.com/ec2/home?region=ap-southeast-2"
+
+	if parsed.Path != "/federation" || parsed.Query().Get("Action") != "login" ||
+		parsed.Query().Get("SigninToken") != "camelid" || parsed.Query().Get("Destination") != expectedDestination {
+		t.Log("---------------")
+		t.Log("Did not create the correct console sign-in URL")
+		t.Logf("Expected destination: %s", expectedDestination)
+		t.Logf("Got: %s", subject)
+		t.Fail()
+	}
+}
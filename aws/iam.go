@@ -0,0 +1,44 @@
+package aws
+
+import (
+	awssdk "github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+// AccountAlias asks IAM for the alias of the account the credentials belong
+// to. Accounts have at most one alias, and may not have any.
+func AccountAlias(creds *sts.AssumeRoleWithSAMLOutput, roleArn string) (string, error) {
+	// IAM is global, but each partition has its own endpoint
+	region, err := StsRegion(roleArn, "")
+
+	if err != nil {
+		return "", err
+	}
+
+	awsConfig := awssdk.NewConfig().WithRegion(region).WithCredentials(credentials.NewStaticCredentials(
+		*creds.Credentials.AccessKeyId,
+		*creds.Credentials.SecretAccessKey,
+		*creds.Credentials.SessionToken,
+	))
+
+	session, err := session.NewSession(awsConfig)
+
+	if err != nil {
+		return "", err
+	}
+
+	output, err := iam.New(session).ListAccountAliases(&iam.ListAccountAliasesInput{})
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(output.AccountAliases) == 0 {
+		return "", nil
+	}
+
+	return *output.AccountAliases[0], nil
+}
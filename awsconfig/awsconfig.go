@@ -0,0 +1,266 @@
+package awsconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const (
+	managedBlockStart = "# BEGIN yak managed profiles"
+	managedBlockEnd   = "# END yak managed profiles"
+)
+
+type Profile struct {
+	Name   string
+	Role   string
+	Region string
+}
+
+var templateFieldPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+var sectionPattern = regexp.MustCompile(`^\s*\[\s*([^\]]+?)\s*\]`)
+
+func ConfigPath() (string, error) {
+	if configPath := os.Getenv("AWS_CONFIG_FILE"); configPath != "" {
+		return configPath, nil
+	}
+
+	home, err := homedir.Dir()
+
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".aws", "config"), nil
+}
+
+func ProfileName(template string, fields map[string]string) (string, error) {
+	var missing []string
+
+	name := templateFieldPattern.ReplaceAllStringFunc(template, func(match string) string {
+		field := match[1 : len(match)-1]
+		value, ok := fields[field]
+
+		if !ok {
+			missing = append(missing, match)
+		}
+
+		return value
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("Unknown field(s) %v in profile name template '%s'", missing, template)
+	}
+
+	// Profile names end up in [section headers], so keep them to one word
+	name = strings.Join(strings.Fields(strings.ReplaceAll(name, "]", "")), "-")
+
+	if name == "" {
+		return "", fmt.Errorf("Profile name template '%s' produced an empty name", template)
+	}
+
+	return name, nil
+}
+
+func RenderManagedBlock(profiles []Profile, yakCommand string) string {
+	sorted := append([]Profile{}, profiles...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	block := strings.Builder{}
+	block.WriteString(managedBlockStart + "\n")
+	block.WriteString("# Generated by yak; changes between these markers will be overwritten\n")
+
+	for _, profile := range sorted {
+		block.WriteString(fmt.Sprintf("\n[profile %s]\n", profile.Name))
+		block.WriteString(fmt.Sprintf("credential_process = %s --credential-process %s\n", yakCommand, profile.Role))
+
+		if profile.Region != "" {
+			block.WriteString(fmt.Sprintf("region = %s\n", profile.Region))
+		}
+	}
+
+	block.WriteString(managedBlockEnd + "\n")
+
+	return block.String()
+}
+
+// ReplaceManagedBlock swaps yak's block for a new one, leaving everything
+// around it alone. If there's no block yet, it's appended.
+func ReplaceManagedBlock(content string, block string) (string, error) {
+	before, after, found, err := splitManagedBlock(content)
+
+	if err != nil {
+		return "", err
+	}
+
+	if !found {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+
+		if content != "" {
+			content += "\n"
+		}
+
+		return content + block, nil
+	}
+
+	return before + block + after, nil
+}
+
+// UnmanagedProfileNames lists the profiles defined outside yak's block, so
+// that we don't generate a second definition of any of them
+func UnmanagedProfileNames(content string) ([]string, error) {
+	before, after, _, err := splitManagedBlock(content)
+
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+
+	for _, line := range strings.Split(before+after, "\n") {
+		match := sectionPattern.FindStringSubmatch(line)
+
+		if match == nil {
+			continue
+		}
+
+		names = append(names, strings.TrimSpace(strings.TrimPrefix(match[1], "profile ")))
+	}
+
+	return names, nil
+}
+
+// splitManagedBlock finds what's before and after yak's block. Without an end
+// marker we can't tell where the block stops, and guessing could throw away
+// the user's own profiles, so that's an error.
+func splitManagedBlock(content string) (string, string, bool, error) {
+	start := strings.Index(content, managedBlockStart)
+
+	if start < 0 {
+		return content, "", false, nil
+	}
+
+	end := strings.Index(content[start:], managedBlockEnd)
+
+	if end < 0 {
+		return "", "", false, fmt.Errorf("Found '%s' without a matching '%s'; please fix the file by hand before yak writes to it", managedBlockStart, managedBlockEnd)
+	}
+
+	end += start + len(managedBlockEnd)
+
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+
+	return content[:start], content[end:], true, nil
+}
+
+func ReadFile(filePath string) (string, error) {
+	data, err := ioutil.ReadFile(filePath)
+
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+
+	return string(data), err
+}
+
+// WriteFileAtomic writes to a temporary file alongside the target and renames
+// it into place, so nothing ever sees a half-written file
+func WriteFileAtomic(filePath string, content string) error {
+	dir := filepath.Dir(filePath)
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(filePath); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tempFile, err := ioutil.TempFile(dir, "."+filepath.Base(filePath)+".yak-")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.WriteString(content); err != nil {
+		tempFile.Close()
+		return err
+	}
+
+	if err := tempFile.Chmod(mode); err != nil {
+		tempFile.Close()
+		return err
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), filePath)
+}
+
+// Diff produces a minimal line-by-line diff, good enough to show what
+// --dry-run would change
+func Diff(oldContent string, newContent string) string {
+	oldLines := splitLines(oldContent)
+	newLines := splitLines(newContent)
+
+	// lengths[i][j] is the length of the longest common subsequence of
+	// oldLines[i:] and newLines[j:]
+	lengths := make([][]int, len(oldLines)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(newLines)+1)
+	}
+
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	diff := strings.Builder{}
+	i, j := 0, 0
+
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			i++
+			j++
+		case i < len(oldLines) && (j == len(newLines) || lengths[i+1][j] >= lengths[i][j+1]):
+			diff.WriteString("-" + oldLines[i] + "\n")
+			i++
+		default:
+			diff.WriteString("+" + newLines[j] + "\n")
+			j++
+		}
+	}
+
+	return diff.String()
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
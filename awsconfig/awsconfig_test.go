@@ -0,0 +1,121 @@
+package awsconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProfileName(t *testing.T) {
+	fields := map[string]string{
+		"account_alias": "camelids",
+		"role_name":     "Llama Admin",
+	}
+
+	name, err := ProfileName("{account_alias}-{role_name}", fields)
+
+	if err != nil || name != "camelids-Llama-Admin" {
+		t.Log("---------------")
+		t.Log("Did not expand the profile name template correctly")
+		t.Logf("Expected: %s", "camelids-Llama-Admin")
+		t.Logf("Got: %s (error: %v)", name, err)
+		t.Fail()
+	}
+
+	if _, err := ProfileName("{account_alias}-{cheese}", fields); err == nil {
+		t.Log("---------------")
+		t.Log("Expanded a template with an unknown field without error")
+		t.Fail()
+	}
+}
+
+func TestReplaceManagedBlock(t *testing.T) {
+	block := RenderManagedBlock([]Profile{
+		{Name: "prod", Role: "prod", Region: "ap-southeast-2"},
+		{Name: "dev", Role: "arn:aws:iam::123456789012:role/dev"},
+	}, "yak")
+
+	expectedBlock := `# BEGIN yak managed profiles
+# Generated by yak; changes between these markers will be overwritten
+
+[profile dev]
+credential_process = yak --credential-process arn:aws:iam::123456789012:role/dev
+
+[profile prod]
+credential_process = yak --credential-process prod
+region = ap-southeast-2
+# END yak managed profiles
+`
+
+	if block != expectedBlock {
+		t.Log("---------------")
+		t.Log("Did not render the managed block correctly")
+		t.Logf("Expected: %s", expectedBlock)
+		t.Logf("Got: %s", block)
+		t.Fail()
+	}
+
+	original := "# My config\n[default]\nregion = us-east-1"
+	withBlock, _ := ReplaceManagedBlock(original, block)
+	expected := original + "\n\n" + block
+
+	if withBlock != expected {
+		t.Log("---------------")
+		t.Log("Did not append the managed block correctly")
+		t.Logf("Expected: %s", expected)
+		t.Logf("Got: %s", withBlock)
+		t.Fail()
+	}
+
+	trailer := "\n[profile mine]\nregion = us-west-2\n"
+	replaced, _ := ReplaceManagedBlock(withBlock+trailer, RenderManagedBlock(nil, "yak"))
+	expected = original + "\n\n" + RenderManagedBlock(nil, "yak") + trailer
+
+	if replaced != expected {
+		t.Log("---------------")
+		t.Log("Did not replace the managed block correctly")
+		t.Logf("Expected: %s", expected)
+		t.Logf("Got: %s", replaced)
+		t.Fail()
+	}
+
+	names, _ := UnmanagedProfileNames(withBlock + trailer)
+
+	if !reflect.DeepEqual(names, []string{"default", "mine"}) {
+		t.Log("---------------")
+		t.Log("Did not find the profiles outside the managed block")
+		t.Logf("Expected: %v", []string{"default", "mine"})
+		t.Logf("Got: %v", names)
+		t.Fail()
+	}
+
+	unterminated := original + "\n" + managedBlockStart + "\n[profile old]\n\n[profile mine]\nregion = us-west-2\n"
+
+	if _, err := ReplaceManagedBlock(unterminated, block); err == nil {
+		t.Log("---------------")
+		t.Log("Replaced a managed block that has no end marker")
+		t.Log("Expected: an error")
+		t.Logf("Got: %v", err)
+		t.Fail()
+	}
+
+	if _, err := UnmanagedProfileNames(unterminated); err == nil {
+		t.Log("---------------")
+		t.Log("Listed profiles around a managed block that has no end marker")
+		t.Log("Expected: an error")
+		t.Logf("Got: %v", err)
+		t.Fail()
+	}
+}
+
+func TestDiff(t *testing.T) {
+	subject := Diff("llama\nalpaca\nvicuña\n", "llama\nguanaco\nvicuña\ncamel\n")
+	expected := "-alpaca\n+guanaco\n+camel\n"
+
+	if subject != expected {
+		t.Log("---------------")
+		t.Log("Did not diff correctly")
+		t.Logf("Expected: %q", expected)
+		t.Logf("Got: %q", subject)
+		t.Fail()
+	}
+}
@@ -0,0 +1,229 @@
+package awsconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+const managedSectionMarker = "# Managed by yak"
+
+const (
+	lockRetryInterval = 100 * time.Millisecond
+	lockTimeout       = 10 * time.Second
+)
+
+type Credentials struct {
+	Role            string
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+	WriteExpiration bool
+}
+
+type iniSection struct {
+	Name  string
+	Lines []string
+}
+
+func CredentialsPath() (string, error) {
+	if credentialsPath := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); credentialsPath != "" {
+		return credentialsPath, nil
+	}
+
+	home, err := homedir.Dir()
+
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".aws", "credentials"), nil
+}
+
+// SetCredentials writes creds into the named profile, replacing it if yak
+// wrote it previously. Profiles yak didn't write are never touched.
+func SetCredentials(content string, profile string, creds Credentials) (string, error) {
+	sections := parseSections(content)
+	newSection := credentialsSection(profile, creds)
+
+	for index, section := range sections {
+		if section.Name != profile {
+			continue
+		}
+
+		if !managedSection(section) {
+			return "", fmt.Errorf("Profile %s in the credentials file wasn't written by yak; not overwriting it", profile)
+		}
+
+		newSection.Lines = append(newSection.Lines, trailingBlankLines(section)...)
+		sections[index] = newSection
+
+		return renderSections(sections), nil
+	}
+
+	if len(sections) > 0 {
+		last := &sections[len(sections)-1]
+
+		if len(last.Lines) > 0 && len(trailingBlankLines(*last)) == 0 {
+			last.Lines = append(last.Lines, "")
+		}
+	}
+
+	return renderSections(append(sections, newSection)), nil
+}
+
+// RemoveExpiredCredentials drops every section yak wrote whose credentials
+// have expired, returning the new content and the profiles removed
+func RemoveExpiredCredentials(content string, now time.Time) (string, []string) {
+	kept := []iniSection{}
+	removed := []string{}
+
+	for _, section := range parseSections(content) {
+		expiration, ok := managedSectionExpiration(section)
+
+		if ok && !expiration.After(now) {
+			removed = append(removed, section.Name)
+			continue
+		}
+
+		kept = append(kept, section)
+	}
+
+	return renderSections(kept), removed
+}
+
+// LockFile takes an exclusive lock on a file through a lock file next to it;
+// call the returned function to release it. The lock belongs to the process
+// holding it, so one that dies can't leave the file locked.
+func LockFile(filePath string) (func(), error) {
+	lockPath := filePath + ".lock"
+
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0700); err != nil {
+		return nil, err
+	}
+
+	lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
+
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		locked, err := tryLock(lock)
+
+		if err != nil {
+			lock.Close()
+			return nil, err
+		}
+
+		// The lock file stays put: removing it could pull it out from under
+		// someone who has just opened it to wait their turn
+		if locked {
+			return func() {
+				unlock(lock)
+				lock.Close()
+			}, nil
+		}
+
+		if time.Now().After(deadline) {
+			lock.Close()
+			return nil, errors.New("Timed out waiting for lock on " + filePath)
+		}
+
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+func credentialsSection(profile string, creds Credentials) iniSection {
+	lines := []string{
+		fmt.Sprintf("[%s]", profile),
+		fmt.Sprintf("%s for %s, expires %s", managedSectionMarker, creds.Role, creds.Expiration.UTC().Format(time.RFC3339)),
+		fmt.Sprintf("aws_access_key_id = %s", creds.AccessKeyId),
+		fmt.Sprintf("aws_secret_access_key = %s", creds.SecretAccessKey),
+		fmt.Sprintf("aws_session_token = %s", creds.SessionToken),
+	}
+
+	if creds.WriteExpiration {
+		lines = append(lines, fmt.Sprintf("expiration = %s", creds.Expiration.UTC().Format(time.RFC3339)))
+	}
+
+	return iniSection{Name: profile, Lines: lines}
+}
+
+func managedSection(section iniSection) bool {
+	return len(section.Lines) > 1 && strings.HasPrefix(strings.TrimSpace(section.Lines[1]), managedSectionMarker)
+}
+
+func managedSectionExpiration(section iniSection) (time.Time, bool) {
+	if !managedSection(section) {
+		return time.Time{}, false
+	}
+
+	marker := strings.TrimSpace(section.Lines[1])
+	index := strings.LastIndex(marker, ", expires ")
+
+	if index < 0 {
+		return time.Time{}, false
+	}
+
+	expiration, err := time.Parse(time.RFC3339, marker[index+len(", expires "):])
+
+	return expiration, err == nil
+}
+
+func trailingBlankLines(section iniSection) []string {
+	blank := []string{}
+
+	for index := len(section.Lines) - 1; index > 0 && strings.TrimSpace(section.Lines[index]) == ""; index-- {
+		blank = append(blank, "")
+	}
+
+	return blank
+}
+
+// parseSections splits an INI file into sections, keeping every line as it
+// was; anything before the first section header has an empty name
+func parseSections(content string) []iniSection {
+	sections := []iniSection{}
+	current := iniSection{}
+
+	for _, line := range splitLines(content) {
+		if match := sectionPattern.FindStringSubmatch(line); match != nil {
+			if current.Name != "" || len(current.Lines) > 0 {
+				sections = append(sections, current)
+			}
+
+			current = iniSection{Name: match[1]}
+		}
+
+		current.Lines = append(current.Lines, line)
+	}
+
+	if current.Name != "" || len(current.Lines) > 0 {
+		sections = append(sections, current)
+	}
+
+	return sections
+}
+
+func renderSections(sections []iniSection) string {
+	lines := []string{}
+
+	for _, section := range sections {
+		lines = append(lines, section.Lines...)
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,159 @@
+package awsconfig
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSetCredentials(t *testing.T) {
+	original := `# Camelid credentials
+[default]
+aws_access_key_id = llama
+
+[prod]
+# Managed by yak for prod, expires 2018-04-04T23:59:54Z
+aws_access_key_id = alpaca
+aws_secret_access_key = alpaca
+aws_session_token = alpaca
+
+[dev]
+aws_access_key_id = vicuña
+`
+
+	creds := Credentials{
+		Role:            "prod",
+		AccessKeyId:     "guanaco",
+		SecretAccessKey: "guanaco-secret",
+		SessionToken:    "guanaco-token",
+		Expiration:      time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		WriteExpiration: true,
+	}
+
+	subject, err := SetCredentials(original, "prod", creds)
+	expected := `# Camelid credentials
+[default]
+aws_access_key_id = llama
+
+[prod]
+# Managed by yak for prod, expires 2030-01-01T00:00:00Z
+aws_access_key_id = guanaco
+aws_secret_access_key = guanaco-secret
+aws_session_token = guanaco-token
+expiration = 2030-01-01T00:00:00Z
+
+[dev]
+aws_access_key_id = vicuña
+`
+
+	if err != nil || subject != expected {
+		t.Log("---------------")
+		t.Log("Did not replace the yak-managed profile correctly")
+		t.Logf("Expected: %s", expected)
+		t.Logf("Got: %s (error: %v)", subject, err)
+		t.Fail()
+	}
+
+	creds.WriteExpiration = false
+	subject, err = SetCredentials(original, "staging", creds)
+	expected = original + `
+[staging]
+# Managed by yak for prod, expires 2030-01-01T00:00:00Z
+aws_access_key_id = guanaco
+aws_secret_access_key = guanaco-secret
+aws_session_token = guanaco-token
+`
+
+	if err != nil || subject != expected {
+		t.Log("---------------")
+		t.Log("Did not add a new profile correctly")
+		t.Logf("Expected: %s", expected)
+		t.Logf("Got: %s (error: %v)", subject, err)
+		t.Fail()
+	}
+
+	if _, err := SetCredentials(original, "default", creds); err == nil {
+		t.Log("---------------")
+		t.Log("Overwrote a profile yak didn't write")
+		t.Fail()
+	}
+}
+
+func TestRemoveExpiredCredentials(t *testing.T) {
+	original := `[default]
+aws_access_key_id = llama
+
+[prod]
+# Managed by yak for prod, expires 2018-04-04T23:59:54Z
+aws_access_key_id = alpaca
+
+[dev]
+# Managed by yak for dev, expires 2030-01-01T00:00:00Z
+aws_access_key_id = vicuña
+`
+
+	subject, removed := RemoveExpiredCredentials(original, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	expected := `[default]
+aws_access_key_id = llama
+
+[dev]
+# Managed by yak for dev, expires 2030-01-01T00:00:00Z
+aws_access_key_id = vicuña
+`
+
+	if subject != expected || len(removed) != 1 || removed[0] != "prod" {
+		t.Log("---------------")
+		t.Log("Did not remove the expired profile correctly")
+		t.Logf("Expected: %s", expected)
+		t.Logf("Got: %s (removed %v)", subject, removed)
+		t.Fail()
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "credentials")
+
+	// As left behind by a yak that died holding the lock
+	ioutil.WriteFile(filePath+".lock", []byte{}, 0600)
+
+	unlock, err := LockFile(filePath)
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("A lock file nobody holds kept the file locked")
+		t.Logf("Error: %v", err)
+		t.FailNow()
+	}
+
+	locked := make(chan func())
+	go func() {
+		second, err := LockFile(filePath)
+
+		if err != nil {
+			second = func() {}
+		}
+
+		locked <- second
+	}()
+
+	select {
+	case second := <-locked:
+		second()
+		t.Log("---------------")
+		t.Log("Took a lock that was already held")
+		t.Fail()
+	case <-time.After(3 * lockRetryInterval):
+	}
+
+	unlock()
+
+	select {
+	case second := <-locked:
+		second()
+	case <-time.After(lockTimeout):
+		t.Log("---------------")
+		t.Log("Did not take the lock once it was released")
+		t.Fail()
+	}
+}
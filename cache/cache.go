@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"encoding/gob"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/service/sts"
@@ -15,6 +16,10 @@ import (
 
 var cacheHandle *gocache.Cache
 
+// exportMutex stops concurrent exports, like yak serve refreshing two roles at
+// once, from writing over each other's cache file
+var exportMutex sync.Mutex
+
 func cache() *gocache.Cache {
 	if cacheHandle == nil {
 		roleExpiryDuration := time.Duration(viper.GetInt("aws.session_duration")) * time.Second
@@ -38,11 +43,23 @@ func gobInit() {
 }
 
 func importCache(roleExpiryDuration time.Duration) error {
+	items, err := readItems()
+
+	if err != nil {
+		return err
+	}
+
+	cacheHandle = gocache.NewFrom(roleExpiryDuration, roleExpiryDuration, items)
+
+	return nil
+}
+
+func readItems() (map[string]gocache.Item, error) {
 	cacheFile, err := os.Open(viper.GetString("cache.file_location"))
 	defer cacheFile.Close()
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	gobInit()
@@ -50,10 +67,44 @@ func importCache(roleExpiryDuration time.Duration) error {
 	var items map[string]gocache.Item
 
 	if err = decoder.Decode(&items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Merge picks up anything other yak processes have cached since this one
+// started, where it would last longer than what's cached here already
+func Merge() error {
+	if !Enabled() {
+		return nil
+	}
+
+	items, err := readItems()
+
+	if err != nil {
 		return err
 	}
 
-	cacheHandle = gocache.NewFrom(roleExpiryDuration, roleExpiryDuration, items)
+	current := cache().Items()
+
+	for key, item := range items {
+		if item.Expired() {
+			continue
+		}
+
+		if existing, ok := current[key]; ok && (existing.Expiration == 0 || existing.Expiration >= item.Expiration) {
+			continue
+		}
+
+		duration := gocache.NoExpiration
+
+		if item.Expiration > 0 {
+			duration = time.Until(time.Unix(0, item.Expiration))
+		}
+
+		cache().Set(key, item.Object, duration)
+	}
 
 	return nil
 }
@@ -93,6 +144,9 @@ func Export() error {
 		return nil
 	}
 
+	exportMutex.Lock()
+	defer exportMutex.Unlock()
+
 	cacheFile, err := os.Create(viper.GetString("cache.file_location"))
 	defer cacheFile.Close()
 
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/redbubble/yak/aws"
+	"github.com/redbubble/yak/cache"
+	"github.com/redbubble/yak/saml"
+)
+
+// Account aliases hardly ever change, and looking one up means assuming a role
+const accountAliasCacheDuration = 7 * 24 * time.Hour
+
+func AccountName(accountId string) string {
+	if name := viper.GetString("accounts." + accountId); name != "" {
+		return name
+	}
+
+	return accountId
+}
+
+// AccountNames finds a name for each account the roles are in: the one set in
+// [accounts] if there is one, or else, if list_roles.lookup_account_aliases is
+// set, the account's alias in IAM. Accounts without a name are left out.
+func AccountNames(roles []saml.LoginRole) map[string]string {
+	names := map[string]string{}
+	unnamed := map[string][]string{}
+	accountIds := []string{}
+
+	for _, role := range roles {
+		parsed, err := arn.Parse(role.RoleArn)
+
+		if err != nil {
+			continue
+		}
+
+		if name := AccountName(parsed.AccountID); name != parsed.AccountID {
+			names[parsed.AccountID] = name
+			continue
+		}
+
+		if _, seen := unnamed[parsed.AccountID]; !seen {
+			accountIds = append(accountIds, parsed.AccountID)
+		}
+
+		unnamed[parsed.AccountID] = append(unnamed[parsed.AccountID], role.RoleArn)
+	}
+
+	if !viper.GetBool("list_roles.lookup_account_aliases") {
+		return names
+	}
+
+	for _, accountId := range accountIds {
+		if alias := lookupAccountAlias(accountId, unnamed[accountId]); alias != "" {
+			names[accountId] = alias
+		}
+	}
+
+	return names
+}
+
+// CachedAccountName names an account without making any requests: from
+// [accounts], or an alias already looked up in IAM. It's empty otherwise.
+func CachedAccountName(accountId string) string {
+	if name := AccountName(accountId); name != accountId {
+		return name
+	}
+
+	alias, _ := cache.Check(accountAliasCacheKey(accountId)).(string)
+
+	return alias
+}
+
+func accountAliasCacheKey(accountId string) string {
+	return "aws:account-alias:" + accountId
+}
+
+// lookupAccountAlias tries each of an account's roles in turn, since not all
+// of them will be allowed to call iam:ListAccountAliases
+func lookupAccountAlias(accountId string, roleArns []string) string {
+	cacheKey := accountAliasCacheKey(accountId)
+
+	if alias, ok := cache.Check(cacheKey).(string); ok {
+		return alias
+	}
+
+	for _, roleArn := range roleArns {
+		creds, err := AssumeRole(Role{Arn: roleArn})
+
+		if err != nil {
+			log.Infof("Could not assume %s to look up the alias of account %s: %v", roleArn, accountId, err)
+			continue
+		}
+
+		alias, err := aws.AccountAlias(creds, roleArn)
+
+		if err != nil {
+			log.Infof("Could not look up the alias of account %s as %s: %v", accountId, roleArn, err)
+			continue
+		}
+
+		log.WithField("alias", alias).Debug("accounts.go: Account alias from IAM for " + accountId)
+
+		// Cache accounts without an alias too, so we don't keep asking
+		cache.Write(cacheKey, alias, accountAliasCacheDuration)
+		cache.Export()
+
+		return alias
+	}
+
+	return ""
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/redbubble/yak/aws"
+)
+
+type Role struct {
+	Alias             string
+	Arn               string
+	Source            string
+	ExternalId        string
+	SessionName       string
+	MfaSerial         string
+	SessionTags       map[string]string
+	TransitiveTagKeys []string
+	SourceIdentity    string
+	SessionDuration   int64
+	Region            string
+	Profile           string
+	Env               map[string]string
+	Description       string
+}
+
+type aliasDefinition struct {
+	RoleArn           string   `mapstructure:"role_arn"`
+	Source            string   `mapstructure:"source"`
+	ExternalId        string   `mapstructure:"external_id"`
+	SessionName       string   `mapstructure:"session_name"`
+	MfaSerial         string   `mapstructure:"mfa_serial"`
+	SessionTags       []string `mapstructure:"session_tags"`
+	TransitiveTagKeys []string `mapstructure:"transitive_tag_keys"`
+	SourceIdentity    string   `mapstructure:"source_identity"`
+	SessionDuration   int64    `mapstructure:"session_duration"`
+	Region            string   `mapstructure:"region"`
+	Profile           string   `mapstructure:"profile"`
+	Env               []string `mapstructure:"env"`
+	Description       string   `mapstructure:"description"`
+}
+
+func (role Role) Chained() bool {
+	return role.Source != ""
+}
+
+func (role Role) sessionDuration() int64 {
+	if role.SessionDuration > 0 {
+		return role.SessionDuration
+	}
+
+	return viper.GetInt64("aws.session_duration")
+}
+
+// SessionLifetime is how long credentials for the role will last, as far as
+// we can tell before asking for them
+func (role Role) SessionLifetime() time.Duration {
+	duration := role.sessionDuration()
+
+	if role.Chained() && duration > aws.MaxChainedSessionDuration {
+		duration = aws.MaxChainedSessionDuration
+	}
+
+	return time.Duration(duration) * time.Second
+}
+
+func GetAliases() (map[string]Role, error) {
+	aliases := map[string]Role{}
+
+	for name, _ := range viper.GetStringMap("alias") {
+		role, err := getAlias(name)
+
+		if err != nil {
+			return aliases, err
+		}
+
+		aliases[name] = role
+	}
+
+	return aliases, nil
+}
+
+// Aliases can be a plain role ARN, or a table describing the role and how to
+// use it, which might include chaining from one of the roles we can assume
+// with SAML
+func getAlias(name string) (Role, error) {
+	key := "alias." + name
+
+	if arn, ok := viper.Get(key).(string); ok {
+		return Role{Alias: name, Arn: arn}, nil
+	}
+
+	var definition aliasDefinition
+
+	if err := viper.UnmarshalKey(key, &definition); err != nil {
+		return Role{}, fmt.Errorf("Could not read alias '%s': %w", name, err)
+	}
+
+	if definition.RoleArn == "" {
+		return Role{}, fmt.Errorf("Alias '%s' has no role_arn", name)
+	}
+
+	role := Role{
+		Alias:             name,
+		Arn:               definition.RoleArn,
+		ExternalId:        definition.ExternalId,
+		SessionName:       definition.SessionName,
+		MfaSerial:         definition.MfaSerial,
+		SessionTags:       parseKeyValuePairs(definition.SessionTags),
+		TransitiveTagKeys: definition.TransitiveTagKeys,
+		SourceIdentity:    definition.SourceIdentity,
+		SessionDuration:   definition.SessionDuration,
+		Region:            definition.Region,
+		Profile:           definition.Profile,
+		Env:               parseKeyValuePairs(definition.Env),
+		Description:       definition.Description,
+	}
+
+	if definition.Source == "" {
+		return role, nil
+	}
+
+	source, err := resolveSourceRole(definition.Source)
+
+	if err != nil {
+		return Role{}, fmt.Errorf("Alias '%s' has an invalid source: %w", name, err)
+	}
+
+	role.Source = source
+
+	return role, nil
+}
+
+func resolveSourceRole(source string) (string, error) {
+	if isIamRoleArn(source) {
+		return source, nil
+	}
+
+	key := "alias." + source
+
+	if !viper.IsSet(key) {
+		return "", fmt.Errorf("'%s' is neither an IAM role ARN nor a configured alias", source)
+	}
+
+	if arn, ok := viper.Get(key).(string); ok {
+		return arn, nil
+	}
+
+	if viper.GetString(key+".source") != "" {
+		return "", fmt.Errorf("'%s' is itself a chained role; only roles assumed with SAML can be used as a source", source)
+	}
+
+	return viper.GetString(key + ".role_arn"), nil
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestResolveRole(t *testing.T) {
+	viper.Set("alias", map[string]interface{}{
+		"identity": "arn:aws:iam::111111111111:role/identity",
+		"prod": map[string]interface{}{
+			"source":      "identity",
+			"role_arn":    "arn:aws:iam::222222222222:role/admin",
+			"external_id": "llama",
+		},
+		"ops": map[string]interface{}{
+			"role_arn":         "arn:aws:iam::444444444444:role/ops",
+			"session_duration": 900,
+			"region":           "ap-southeast-2",
+			"profile":          "operations",
+			"env":              []string{"TF_VAR_environment=ops", "KUBECONFIG=~/.kube/ops"},
+			"description":      "Day-to-day operations",
+		},
+		"staging": map[string]interface{}{
+			"source":   "prod",
+			"role_arn": "arn:aws:iam::333333333333:role/admin",
+		},
+	})
+	defer viper.Set("alias", nil)
+
+	scenarios := []struct {
+		name     string
+		expected Role
+	}{
+		{"identity", Role{Alias: "identity", Arn: "arn:aws:iam::111111111111:role/identity"}},
+		{"arn:aws:iam::444444444444:role/direct", Role{Arn: "arn:aws:iam::444444444444:role/direct"}},
+		{"prod", Role{
+			Alias:       "prod",
+			Arn:         "arn:aws:iam::222222222222:role/admin",
+			Source:      "arn:aws:iam::111111111111:role/identity",
+			ExternalId:  "llama",
+			SessionTags: map[string]string{},
+			Env:         map[string]string{},
+		}},
+		{"ops", Role{
+			Alias:           "ops",
+			Arn:             "arn:aws:iam::444444444444:role/ops",
+			SessionTags:     map[string]string{},
+			SessionDuration: 900,
+			Region:          "ap-southeast-2",
+			Profile:         "operations",
+			Env:             map[string]string{"TF_VAR_environment": "ops", "KUBECONFIG": "~/.kube/ops"},
+			Description:     "Day-to-day operations",
+		}},
+	}
+
+	for _, scenario := range scenarios {
+		role, err := ResolveRole(scenario.name)
+
+		if err != nil || !reflect.DeepEqual(role, scenario.expected) {
+			t.Log("---------------")
+			t.Logf("Did not correctly resolve %s", scenario.name)
+			t.Logf("Expected: %+v", scenario.expected)
+			t.Logf("Got: %+v (error: %v)", role, err)
+			t.Fail()
+		}
+	}
+
+	for _, name := range []string{"staging", "alpaca"} {
+		if _, err := ResolveRole(name); err == nil {
+			t.Log("---------------")
+			t.Logf("Resolved %s without error", name)
+			t.Fail()
+		}
+	}
+}
+
+func TestSessionLifetime(t *testing.T) {
+	viper.Set("aws.session_duration", 43200)
+	defer viper.Set("aws.session_duration", nil)
+
+	scenarios := []struct {
+		role     Role
+		expected time.Duration
+	}{
+		{Role{Arn: "arn:aws:iam::111111111111:role/identity"}, 12 * time.Hour},
+		{Role{Arn: "arn:aws:iam::111111111111:role/identity", SessionDuration: 900}, 15 * time.Minute},
+		// AWS caps chained sessions at an hour
+		{Role{Arn: "arn:aws:iam::222222222222:role/admin", Source: "identity"}, time.Hour},
+	}
+
+	for _, scenario := range scenarios {
+		if lifetime := scenario.role.SessionLifetime(); lifetime != scenario.expected {
+			t.Log("---------------")
+			t.Logf("Wrong session lifetime for %+v", scenario.role)
+			t.Logf("Expected: %s", scenario.expected)
+			t.Logf("Got: %s", lifetime)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/viper"
+
+	"github.com/redbubble/yak/saml"
+	log "github.com/sirupsen/logrus"
+)
+
+type assertionFileProvider struct {
+	path        string
+	samlPayload string
+}
+
+func UsingAssertionFile() bool {
+	return viper.GetString("saml_assertion_file") != ""
+}
+
+func newAssertionFileProvider() (*assertionFileProvider, error) {
+	return &assertionFileProvider{path: viper.GetString("saml_assertion_file")}, nil
+}
+
+func (provider *assertionFileProvider) Authenticate() error {
+	var data []byte
+	var err error
+
+	if provider.path == "-" {
+		log.Infof("Reading SAML assertion from stdin")
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		log.Infof("Reading SAML assertion from %s", provider.path)
+		data, err = ioutil.ReadFile(provider.path)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	provider.samlPayload, err = saml.DecodeAssertion(data)
+
+	return err
+}
+
+func (provider *assertionFileProvider) SamlAssertion() (string, error) {
+	return provider.samlPayload, nil
+}
@@ -3,6 +3,10 @@ package cli
 import (
 	"errors"
 	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws/arn"
 	"github.com/aws/aws-sdk-go/service/sts"
@@ -18,8 +22,45 @@ var notARoleErrorMessage = `'%s' is neither an IAM role ARN nor a configured ali
 
 Run 'yak --list-roles' to see which roles and aliases you can use.`
 
-func AssumeRole(role string) (*sts.AssumeRoleWithSAMLOutput, error) {
-	creds := getAssumedRoleFromCache(role)
+func AssumeRole(role Role) (*sts.AssumeRoleWithSAMLOutput, error) {
+	return AssumeRoleWithMinimumLifetime(role, 0)
+}
+
+// ErrPromptRequired means the credentials can't be had without asking the
+// user to log in or for an MFA token
+var ErrPromptRequired = errors.New("Getting credentials for this role needs a login or MFA prompt")
+
+// AssumeRoleWithMinimumLifetime works like AssumeRole, but won't use cached
+// credentials that are due to expire within the given lifetime
+func AssumeRoleWithMinimumLifetime(role Role, lifetime time.Duration) (*sts.AssumeRoleWithSAMLOutput, error) {
+	return assumeRole(role, lifetime, true)
+}
+
+// AssumeRoleWithoutPrompting works like AssumeRoleWithMinimumLifetime, for
+// when there's no one to prompt: it makes do with cached credentials and
+// recent logins, and returns ErrPromptRequired if that's not enough
+func AssumeRoleWithoutPrompting(role Role, lifetime time.Duration) (*sts.AssumeRoleWithSAMLOutput, error) {
+	return assumeRole(role, lifetime, false)
+}
+
+func assumeRole(role Role, lifetime time.Duration, prompt bool) (*sts.AssumeRoleWithSAMLOutput, error) {
+	if !prompt {
+		// Someone may have logged in with another yak since we started
+		if err := cache.Merge(); err != nil {
+			log.WithField("error", err).Debug("assume_role.go: Could not merge cache file")
+		}
+	}
+
+	if role.Chained() {
+		return assumeChainedRole(role, lifetime, prompt)
+	}
+
+	return assumeSamlRole(role.Arn, role.sessionDuration(), lifetime, prompt)
+}
+
+func assumeSamlRole(role string, duration int64, lifetime time.Duration, prompt bool) (*sts.AssumeRoleWithSAMLOutput, error) {
+	cacheKey := samlRoleCacheKey(role, duration)
+	creds := getAssumedRoleFromCache(cacheKey, lifetime)
 
 	if creds == nil {
 		log.Infof("Role %s not in cache", role)
@@ -27,14 +68,14 @@ func AssumeRole(role string) (*sts.AssumeRoleWithSAMLOutput, error) {
 			return nil, errors.New("Could not find credentials in cache and --cache-only specified. Run `yak <role>` to remedy.")
 		}
 
-		loginData, err := GetLoginDataWithTimeout()
+		loginData, err := getLoginDataFor(prompt)
 
 		if err != nil {
 			return nil, err
 		}
 
 		CacheLoginRoles(loginData.Roles)
-		creds, err = assumeRoleFromAws(loginData, role)
+		creds, err = assumeRoleFromAws(loginData, role, duration)
 
 		if err != nil {
 			return nil, err
@@ -42,36 +83,221 @@ func AssumeRole(role string) (*sts.AssumeRoleWithSAMLOutput, error) {
 
 		log.WithField("role", creds).Debug("assume_role.go: Role assumption credentials from AWS")
 
-		cache.WriteDefault(role, creds)
-		cache.Export()
+		cacheAssumedRole(cacheKey, creds)
+	}
+
+	return creds, nil
+}
+
+func assumeChainedRole(role Role, lifetime time.Duration, prompt bool) (*sts.AssumeRoleWithSAMLOutput, error) {
+	chainedRole := chainedRoleInput(role)
+
+	if err := chainedRole.Validate(); err != nil {
+		return nil, fmt.Errorf("Cannot assume %s: %w", role.Arn, err)
+	}
+
+	cacheKey := chainedRoleCacheKey(role.Source, chainedRole, role.sessionDuration())
+	creds := getAssumedRoleFromCache(cacheKey, lifetime)
+
+	if creds != nil {
+		return creds, nil
+	}
+
+	log.Infof("Chained role %s not in cache", role.Arn)
+	if viper.GetBool("cache.cache_only") {
+		return nil, errors.New("Could not find credentials in cache and --cache-only specified. Run `yak <role>` to remedy.")
+	}
+
+	sourceCreds, err := assumeSamlRole(role.Source, viper.GetInt64("aws.session_duration"), 0, prompt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if chainedRole.SessionName == "" {
+		chainedRole.SessionName = aws.SessionName(sourceCreds)
+	}
+
+	if role.MfaSerial != "" {
+		chainedRole.SerialNumber = role.MfaSerial
+		chainedRole.TokenCode, err = getMfaTokenCode(role.MfaSerial, prompt)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Infof("Assuming role %s from %s", role.Arn, role.Source)
+
+	creds, err = aws.ChainRole(
+		sourceCreds,
+		chainedRole,
+		role.sessionDuration(),
+		stsConfig(),
+	)
+
+	if err != nil {
+		return nil, err
 	}
 
+	log.WithField("role", creds).Debug("assume_role.go: Chained role assumption credentials from AWS")
+
+	cacheAssumedRole(cacheKey, creds)
+
 	return creds, nil
 }
 
-func getAssumedRoleFromCache(role string) *sts.AssumeRoleWithSAMLOutput {
+// cacheAssumedRole keeps credentials for exactly as long as they last, which
+// depends on the session duration they were asked for
+func cacheAssumedRole(key string, creds *sts.AssumeRoleWithSAMLOutput) {
+	cache.Write(key, *creds, time.Until(*creds.Credentials.Expiration))
+	cache.Export()
+}
+
+// chainedRoleInput fills in anything the alias doesn't specify from the
+// global defaults in the [aws] section
+func chainedRoleInput(role Role) aws.ChainedRole {
+	input := aws.ChainedRole{
+		RoleArn:           role.Arn,
+		ExternalId:        role.ExternalId,
+		SessionName:       role.SessionName,
+		SessionTags:       map[string]string{},
+		TransitiveTagKeys: role.TransitiveTagKeys,
+		SourceIdentity:    role.SourceIdentity,
+	}
+
+	if input.SessionName == "" {
+		input.SessionName = viper.GetString("aws.session_name")
+	}
+
+	// Usernames aren't always valid session names, but a name set explicitly
+	// is left alone so that a mistake in it gets reported
+	if input.SessionName == "" {
+		input.SessionName = aws.CleanSessionName(oktaUsername())
+	}
+
+	if input.SourceIdentity == "" {
+		input.SourceIdentity = viper.GetString("aws.source_identity")
+	}
+
+	if len(input.TransitiveTagKeys) == 0 {
+		input.TransitiveTagKeys = viper.GetStringSlice("aws.transitive_tag_keys")
+	}
+
+	for key, value := range parseKeyValuePairs(viper.GetStringSlice("aws.session_tags")) {
+		input.SessionTags[key] = value
+	}
+
+	for key, value := range role.SessionTags {
+		input.SessionTags[key] = value
+	}
+
+	return input
+}
+
+// Tags and variables are written as "Key=Value" strings rather than a TOML
+// table, because Viper would lowercase the keys of a table
+func parseKeyValuePairs(pairs []string) map[string]string {
+	parsed := map[string]string{}
+
+	for _, pair := range pairs {
+		key, value, _ := strings.Cut(pair, "=")
+		parsed[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return parsed
+}
+
+func getMfaTokenCode(serial string, prompt bool) (string, error) {
+	command := strings.Fields(viper.GetString("aws.mfa_token_command"))
+
+	if len(command) == 0 {
+		if !prompt {
+			return "", ErrPromptRequired
+		}
+
+		return promptOrPinentry(fmt.Sprintf("AWS MFA token (for %s): ", serial), false)
+	}
+
+	log.Infof("Generating MFA token for %s with %s", serial, command[0])
+	output, err := exec.Command(command[0], command[1:]...).Output()
+
+	if err != nil {
+		return "", fmt.Errorf("Could not generate MFA token: %w", err)
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// samlRoleCacheKey includes the session duration, so that aliases asking for
+// shorter sessions don't get handed longer ones cached for another alias
+func samlRoleCacheKey(roleArn string, duration int64) string {
+	return fmt.Sprintf("%s#%d", roleArn, duration)
+}
+
+func chainedRoleCacheKey(source string, role aws.ChainedRole, duration int64) string {
+	tags := []string{}
+
+	for key, value := range role.SessionTags {
+		tags = append(tags, key+"="+value)
+	}
+
+	sort.Strings(tags)
+
+	transitiveTagKeys := append([]string{}, role.TransitiveTagKeys...)
+	sort.Strings(transitiveTagKeys)
+
+	return fmt.Sprintf(
+		"aws:chain:%s:%s:%s:%s:%s:%s:%s:%d",
+		source,
+		role.RoleArn,
+		role.ExternalId,
+		role.SessionName,
+		role.SourceIdentity,
+		strings.Join(tags, ","),
+		strings.Join(transitiveTagKeys, ","),
+		duration,
+	)
+}
+
+func getAssumedRoleFromCache(role string, lifetime time.Duration) *sts.AssumeRoleWithSAMLOutput {
 	data, ok := cache.Check(role).(sts.AssumeRoleWithSAMLOutput)
 
 	if !ok {
 		return nil
 	}
 
+	if lifetime > 0 && data.Credentials != nil && data.Credentials.Expiration != nil && time.Until(*data.Credentials.Expiration) < lifetime {
+		log.Infof("Cached credentials for %s expire at %s, too soon to use", role, data.Credentials.Expiration.String())
+		return nil
+	}
+
 	return &data
 }
 
-func ResolveRole(roleName string) (string, error) {
+func ResolveRole(roleName string) (Role, error) {
+	if IsRoleSelector(roleName) {
+		name, err := selectRole(roleName)
+
+		if err != nil {
+			return Role{}, err
+		}
+
+		roleName = name
+	}
+
 	if viper.IsSet("alias." + roleName) {
-		return viper.GetString("alias." + roleName), nil
+		return getAlias(roleName)
 	}
 
 	if isIamRoleArn(roleName) {
-		return roleName, nil
+		return Role{Arn: roleName}, nil
 	}
 
-	return "", fmt.Errorf(notARoleErrorMessage, roleName)
+	return Role{}, fmt.Errorf(notARoleErrorMessage, roleName)
 }
 
-func assumeRoleFromAws(login saml.LoginData, desiredRole string) (*sts.AssumeRoleWithSAMLOutput, error) {
+func assumeRoleFromAws(login saml.LoginData, desiredRole string, duration int64) (*sts.AssumeRoleWithSAMLOutput, error) {
 	log.Infof("Assuming role %s from AWS", desiredRole)
 
 	role, err := login.GetLoginRole(desiredRole)
@@ -80,7 +306,45 @@ func assumeRoleFromAws(login saml.LoginData, desiredRole string) (*sts.AssumeRol
 		return nil, err
 	}
 
-	return aws.AssumeRole(login, role, viper.GetInt64("aws.session_duration"))
+	return aws.AssumeRole(login, role, duration, stsConfig())
+}
+
+func stsConfig() aws.StsConfig {
+	return aws.StsConfig{
+		Region:   viper.GetString("aws.sts_region"),
+		Endpoint: viper.GetString("aws.sts_endpoint"),
+	}
+}
+
+func RegionEnvironmentVariables(role Role) map[string]string {
+	if role.Region != "" {
+		return aws.RegionEnvironmentVariables(role.Region)
+	}
+
+	if region := viper.GetString("aws.region"); region != "" {
+		return aws.RegionEnvironmentVariables(region)
+	}
+
+	return aws.RegionEnvironmentVariables(viper.GetString("aws.sts_region"))
+}
+
+// RoleEnvironmentVariables tell a command which role yak ran it as, and
+// which alias, if the role was given as one, along with any variables the
+// alias sets
+func RoleEnvironmentVariables(role Role) map[string]string {
+	subject := map[string]string{}
+
+	for key, value := range role.Env {
+		subject[key] = value
+	}
+
+	subject["YAK_ROLE"] = role.Arn
+
+	if role.Alias != "" {
+		subject["YAK_ALIAS"] = role.Alias
+	}
+
+	return subject
 }
 
 func isIamRoleArn(roleName string) bool {
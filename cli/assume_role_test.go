@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/spf13/viper"
+)
+
+func TestCacheAssumedRole(t *testing.T) {
+	viper.Set("cache.file_location", filepath.Join(t.TempDir(), "cache"))
+	defer viper.Set("cache.file_location", nil)
+
+	accessKeyId := "llama"
+	expiration := time.Now().Add(100 * time.Millisecond)
+	creds := sts.AssumeRoleWithSAMLOutput{
+		Credentials: &sts.Credentials{AccessKeyId: &accessKeyId, Expiration: &expiration},
+	}
+
+	cacheAssumedRole("arn:aws:iam::123456789012:role/short", &creds)
+
+	if getAssumedRoleFromCache("arn:aws:iam::123456789012:role/short", 0) == nil {
+		t.Log("---------------")
+		t.Log("Did not cache credentials that are still valid")
+		t.Log("Expected: the cached credentials")
+		t.Log("Got: nothing")
+		t.Fail()
+	}
+
+	// Credentials from an alias with a short session_duration have to leave
+	// the cache when they expire, not after the default session duration
+	time.Sleep(200 * time.Millisecond)
+
+	if cached := getAssumedRoleFromCache("arn:aws:iam::123456789012:role/short", 0); cached != nil {
+		t.Log("---------------")
+		t.Log("Kept expired credentials in the cache")
+		t.Log("Expected: nothing")
+		t.Logf("Got: credentials expiring at %s", cached.Credentials.Expiration)
+		t.Fail()
+	}
+}
+
+func TestAssumeRoleCachesPerSessionDuration(t *testing.T) {
+	viper.Set("cache.file_location", filepath.Join(t.TempDir(), "cache"))
+	defer viper.Set("cache.file_location", nil)
+	viper.Set("cache.cache_only", true)
+	defer viper.Set("cache.cache_only", nil)
+	viper.Set("alias", map[string]interface{}{
+		"brief": map[string]interface{}{
+			"role_arn":         "arn:aws:iam::123456789012:role/shared",
+			"session_duration": 900,
+		},
+		"lengthy": map[string]interface{}{
+			"role_arn":         "arn:aws:iam::123456789012:role/shared",
+			"session_duration": 43200,
+		},
+	})
+	defer viper.Set("alias", nil)
+
+	accessKeyId := "llama"
+	expiration := time.Now().Add(12 * time.Hour)
+	creds := sts.AssumeRoleWithSAMLOutput{
+		Credentials: &sts.Credentials{AccessKeyId: &accessKeyId, Expiration: &expiration},
+	}
+
+	cacheAssumedRole(samlRoleCacheKey("arn:aws:iam::123456789012:role/shared", 43200), &creds)
+
+	lengthy, _ := ResolveRole("lengthy")
+
+	if cached, err := AssumeRole(lengthy); err != nil || *cached.Credentials.AccessKeyId != accessKeyId {
+		t.Log("---------------")
+		t.Log("Did not use credentials cached for the same session duration")
+		t.Logf("Expected: %s", accessKeyId)
+		t.Logf("Got: %+v (error: %v)", cached, err)
+		t.Fail()
+	}
+
+	brief, _ := ResolveRole("brief")
+
+	if cached, err := AssumeRole(brief); err == nil {
+		t.Log("---------------")
+		t.Log("Used credentials cached for a longer session duration")
+		t.Log("Expected: no cached credentials")
+		t.Logf("Got: %+v", cached)
+		t.Fail()
+	}
+}
+
+func TestChainedRoleInputSessionName(t *testing.T) {
+	viper.Set("okta.username", "CAMELIDS\\llama")
+	defer viper.Set("okta.username", nil)
+
+	role := Role{Arn: "arn:aws:iam::222222222222:role/admin", Source: "arn:aws:iam::111111111111:role/identity"}
+
+	if name := chainedRoleInput(role).SessionName; name != "CAMELIDS-llama" {
+		t.Log("---------------")
+		t.Log("Did not turn the Okta username into a valid session name")
+		t.Log("Expected: CAMELIDS-llama")
+		t.Logf("Got: %s", name)
+		t.Fail()
+	}
+
+	role.SessionName = "llama alpaca"
+
+	if name := chainedRoleInput(role).SessionName; name != "llama alpaca" {
+		t.Log("---------------")
+		t.Log("Changed a session name that was set explicitly")
+		t.Log("Expected: llama alpaca")
+		t.Logf("Got: %s", name)
+		t.Fail()
+	}
+}
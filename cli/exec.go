@@ -4,18 +4,78 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
 )
 
-func EnrichedEnvironment(extraEnv map[string]string) []string {
-	env := os.Environ()
+// These describe the role yak was run with, so they mustn't leak through
+// from an outer yak into a command run as some other role
+var yakVariables = []string{"YAK_ROLE", "YAK_ALIAS"}
+
+// EnrichedEnvironment adds variables to yak's environment, replacing any
+// that were already set, and dropping the ones configured to be stripped
+func EnrichedEnvironment(extraEnvs ...map[string]string) []string {
+	strip := append(viper.GetStringSlice("exec.strip_variables"), yakVariables...)
+	extraEnv := map[string]string{}
+
+	for _, env := range extraEnvs {
+		for key, value := range env {
+			extraEnv[key] = value
+			strip = append(strip, key)
+		}
+	}
+
+	keys := make([]string, 0, len(extraEnv))
+	for key := range extraEnv {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	env := WithoutVariables(os.Environ(), strip...)
 
-	for key, value := range extraEnv {
-		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	for _, key := range keys {
+		env = append(env, fmt.Sprintf("%s=%s", key, extraEnv[key]))
 	}
 
 	return env
 }
 
+// WithoutVariables removes the named variables from an environment
+func WithoutVariables(env []string, keys ...string) []string {
+	stripped := []string{}
+
+	for _, variable := range env {
+		name := strings.SplitN(variable, "=", 2)[0]
+		keep := true
+
+		for _, key := range keys {
+			if name == key {
+				keep = false
+				break
+			}
+		}
+
+		if keep {
+			stripped = append(stripped, variable)
+		}
+	}
+
+	return stripped
+}
+
+// signalForwarding says which signals sent to yak go on to the command, and
+// which yak only needs to survive because the command gets them anyway
+type signalForwarding struct {
+	forwarded []os.Signal
+	ignored   []os.Signal
+	forward   func(os.Signal)
+}
+
+// Exec runs the command and waits for it to finish, passing on any signals
+// sent to yak in the meantime
 func Exec(command []string, environment []string) error {
 	cmd := exec.Command(command[0], command[1:]...)
 	cmd.Env = environment
@@ -24,12 +84,41 @@ func Exec(command []string, environment []string) error {
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
 
+	forwarding := prepareCommand(cmd)
+
+	ignored := make(chan os.Signal, 4)
+	if len(forwarding.ignored) > 0 {
+		signal.Notify(ignored, forwarding.ignored...)
+		defer signal.Stop(ignored)
+	}
+
+	// Notify relays every signal when given none, so only ask when there are some
+	signals := make(chan os.Signal, 4)
+	if len(forwarding.forwarded) > 0 {
+		signal.Notify(signals, forwarding.forwarded...)
+		defer signal.Stop(signals)
+	}
+
 	err := cmd.Start()
 
 	if err != nil {
 		return err
 	}
 
-	err = cmd.Wait()
-	return err
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				forwarding.forward(sig)
+			case <-ignored:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return cmd.Wait()
 }
@@ -4,6 +4,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/spf13/viper"
 )
 
 func TestEnrichedEnvironment(t *testing.T) {
@@ -14,10 +16,14 @@ func TestEnrichedEnvironment(t *testing.T) {
 
 	subject := EnrichedEnvironment(extraVars)
 
-	if len(subject) != len(os.Environ())+2 {
+	// yak's own variables never make it through, in case the tests are run
+	// from inside a yak session
+	expectedLength := len(WithoutVariables(os.Environ(), yakVariables...)) + 2
+
+	if len(subject) != expectedLength {
 		t.Log("---------------")
 		t.Log("Did not inject the environment correctly")
-		t.Logf("Expected length: %d", len(os.Environ())+2)
+		t.Logf("Expected length: %d", expectedLength)
 		t.Logf("Got: %d", len(subject))
 		t.Fail()
 	}
@@ -48,3 +54,60 @@ func TestEnrichedEnvironment(t *testing.T) {
 		}
 	}
 }
+
+func TestWithoutVariables(t *testing.T) {
+	env := []string{"CHEESE=Challerhocker", "CAMELID=Dromedary", "CAMELIDAE=Family", "EMPTY="}
+
+	subject := WithoutVariables(env, "CAMELID", "EMPTY")
+	expected := []string{"CHEESE=Challerhocker", "CAMELIDAE=Family"}
+
+	if strings.Join(subject, " ") != strings.Join(expected, " ") {
+		t.Log("---------------")
+		t.Log("Did not strip the right variables from the environment")
+		t.Logf("Expected: %v", expected)
+		t.Logf("Got: %v", subject)
+		t.Fail()
+	}
+}
+
+func TestEnrichedEnvironmentReplacesVariables(t *testing.T) {
+	t.Setenv("CAMELID", "Dromedary")
+	t.Setenv("AWS_PROFILE", "stale")
+	t.Setenv("YAK_ALIAS", "outer")
+	viper.Set("exec.strip_variables", []string{"AWS_PROFILE"})
+	defer viper.Set("exec.strip_variables", nil)
+
+	subject := EnrichedEnvironment(map[string]string{"CAMELID": "Bactrian"}, map[string]string{"YAK_ROLE": "arn:aws:iam::123456789012:role/llama"})
+
+	values := map[string][]string{}
+	for _, variable := range subject {
+		parts := strings.SplitN(variable, "=", 2)
+		values[parts[0]] = append(values[parts[0]], parts[1])
+	}
+
+	if len(values["CAMELID"]) != 1 || values["CAMELID"][0] != "Bactrian" {
+		t.Log("---------------")
+		t.Log("Did not replace an existing variable")
+		t.Logf("Expected: [Bactrian]")
+		t.Logf("Got: %v", values["CAMELID"])
+		t.Fail()
+	}
+
+	if _, ok := values["AWS_PROFILE"]; ok {
+		t.Log("---------------")
+		t.Log("Did not strip a configured variable")
+		t.Fail()
+	}
+
+	if _, ok := values["YAK_ALIAS"]; ok {
+		t.Log("---------------")
+		t.Log("Let YAK_ALIAS through from the outer environment")
+		t.Fail()
+	}
+
+	if len(values["YAK_ROLE"]) != 1 {
+		t.Log("---------------")
+		t.Log("Did not set YAK_ROLE")
+		t.Fail()
+	}
+}
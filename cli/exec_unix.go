@@ -0,0 +1,95 @@
+//go:build !windows
+
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// Signals sent to yak itself, which the command wouldn't otherwise see
+var forwardedSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP}
+
+// The terminal sends these to the command as well as to yak, when they share
+// a process group in the foreground
+var terminalSignals = []os.Signal{syscall.SIGINT, syscall.SIGQUIT}
+
+// prepareCommand picks a process group for the command, and with it how
+// signals get passed on, so that anything the command starts (like the
+// children of a wrapper script) gets them too.
+func prepareCommand(cmd *exec.Cmd) signalForwarding {
+	if !inTerminalForeground() {
+		// Nothing needs the command in yak's process group, so give it one of
+		// its own that every signal can go to without reaching yak's parent
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+		return signalForwarding{
+			forwarded: append(append([]os.Signal{}, forwardedSignals...), terminalSignals...),
+			forward: func(sig os.Signal) {
+				signalGroup(cmd, -cmd.Process.Pid, sig)
+			},
+		}
+	}
+
+	// In the foreground the command has to share yak's process group to use
+	// the terminal. Ctrl-C reaches them both, and Ctrl-Z isn't caught at all,
+	// so the shell stops and continues them together.
+	forwarding := signalForwarding{
+		forwarded: forwardedSignals,
+		ignored:   terminalSignals,
+		forward: func(sig os.Signal) {
+			log.WithField("signal", sig).Debug("exec_unix.go: Forwarding signal to command")
+			cmd.Process.Signal(sig)
+		},
+	}
+
+	// The group is only ours to signal if it's yak's own job; otherwise it
+	// holds whatever started yak, like make
+	if syscall.Getpgrp() != os.Getpid() {
+		return forwarding
+	}
+
+	// Signalling our own group signals yak again, and that copy mustn't be
+	// passed on a second time
+	echoes := map[os.Signal]int{}
+	forwarding.forward = func(sig os.Signal) {
+		if echoes[sig] > 0 {
+			echoes[sig]--
+			return
+		}
+
+		echoes[sig]++
+		signalGroup(cmd, -syscall.Getpgrp(), sig)
+	}
+
+	return forwarding
+}
+
+func signalGroup(cmd *exec.Cmd, group int, sig os.Signal) {
+	log.WithField("signal", sig).Debug("exec_unix.go: Forwarding signal to command's process group")
+
+	if err := syscall.Kill(group, sig.(syscall.Signal)); err != nil {
+		cmd.Process.Signal(sig)
+	}
+}
+
+func inTerminalForeground() bool {
+	foreground, err := unix.IoctlGetInt(int(os.Stdin.Fd()), unix.TIOCGPGRP)
+
+	return err == nil && foreground == syscall.Getpgrp()
+}
+
+// ReplaceProcess runs the command in place of yak, rather than as a child of it
+func ReplaceProcess(command []string, environment []string) error {
+	path, err := exec.LookPath(command[0])
+
+	if err != nil {
+		return err
+	}
+
+	return syscall.Exec(path, command, environment)
+}
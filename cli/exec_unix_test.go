@@ -0,0 +1,89 @@
+//go:build !windows
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestExecSignalledCommand(t *testing.T) {
+	err := Exec([]string{"sh", "-c", "kill -TERM $$"}, os.Environ())
+
+	exitError, ok := err.(*exec.ExitError)
+
+	if !ok {
+		t.Log("---------------")
+		t.Log("Did not return the command's exit error")
+		t.Logf("Got: %v", err)
+		t.FailNow()
+	}
+
+	status := exitError.Sys().(syscall.WaitStatus)
+
+	if !status.Signaled() || status.Signal() != syscall.SIGTERM {
+		t.Log("---------------")
+		t.Log("Did not report that the command was killed by a signal")
+		t.Logf("Expected: %v", syscall.SIGTERM)
+		t.Logf("Got: %v", status)
+		t.Fail()
+	}
+}
+
+func TestExecForwardsSignalsToGrandchildren(t *testing.T) {
+	// Away from a terminal's foreground, the command gets a process group of its own
+	stdin := os.Stdin
+	os.Stdin, _ = os.Open(os.DevNull)
+	defer func() { os.Stdin = stdin }()
+
+	dir := t.TempDir()
+	ready := filepath.Join(dir, "ready")
+	received := filepath.Join(dir, "received")
+
+	// A wrapper script whose child outlives it unless it gets the signal too
+	grandchild := filepath.Join(dir, "grandchild.sh")
+	ioutil.WriteFile(grandchild, []byte(`trap 'echo TERM > "$RECEIVED"; exit 0' TERM; touch "$READY"; while :; do sleep 0.1; done`), 0600)
+	env := append(os.Environ(), "READY="+ready, "RECEIVED="+received)
+
+	finished := make(chan error)
+	go func() {
+		finished <- Exec([]string{"sh", "-c", `sh "$0" & wait`, grandchild}, env)
+	}()
+
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(50 * time.Millisecond) {
+		if _, err := os.Stat(ready); err == nil {
+			break
+		}
+	}
+
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Log("---------------")
+		t.Log("The command did not finish after being sent SIGTERM")
+		t.FailNow()
+	}
+
+	// The grandchild may still be finishing up once its parent has gone
+	var content []byte
+	for start := time.Now(); time.Since(start) < 2*time.Second; time.Sleep(50 * time.Millisecond) {
+		if content, _ = ioutil.ReadFile(received); len(content) > 0 {
+			break
+		}
+	}
+
+	if string(content) != "TERM\n" {
+		t.Log("---------------")
+		t.Log("Did not pass SIGTERM on to the command's children")
+		t.Log("Expected: TERM")
+		t.Logf("Got: %q", content)
+		t.Fail()
+	}
+}
@@ -0,0 +1,20 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Windows hands Ctrl-C to every process attached to the console, so the
+// command has already seen it; yak just needs to not die of it first
+func prepareCommand(cmd *exec.Cmd) signalForwarding {
+	return signalForwarding{
+		ignored: []os.Signal{os.Interrupt},
+		forward: func(os.Signal) {},
+	}
+}
+
+// ReplaceProcess can't replace yak on Windows, so runs the command as a child instead
+func ReplaceProcess(command []string, environment []string) error {
+	return Exec(command, environment)
+}
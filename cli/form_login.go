@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/viper"
+
+	"github.com/redbubble/yak/formpost"
+	log "github.com/sirupsen/logrus"
+)
+
+type formPostProvider struct {
+	samlPayload string
+}
+
+func newFormPostProvider() (*formPostProvider, error) {
+	if viper.GetString("form.login_url") == "" {
+		return nil, errors.New(`A login URL must be configured to use the form identity provider.
+This can be configured either in the [form] section of ~/.config/yak/config.toml or by passing the --form-login-url argument.`)
+	}
+
+	return &formPostProvider{}, nil
+}
+
+func (provider *formPostProvider) Authenticate() error {
+	if viper.GetBool("cache.cache_only") {
+		return errors.New("Could not find credentials in cache and --cache-only specified. Run `yak <role>` to remedy.")
+	}
+
+	log.Infof("Logging in to %s", viper.GetString("form.login_url"))
+
+	username := viper.GetString("form.username")
+	promptUsername := (username == "")
+	var err error
+
+	if promptUsername {
+		fmt.Fprint(os.Stderr, "Username: ")
+		username, err = getLine()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	prompt := "Password"
+	if !promptUsername {
+		prompt = prompt + " (" + username + ")"
+	}
+
+	password, err := promptOrPinentry(fmt.Sprintf("%s: ", prompt), true)
+
+	if err != nil {
+		return err
+	}
+
+	provider.samlPayload, err = formpost.Login(
+		viper.GetString("form.login_url"),
+		formpost.FieldNames{
+			Username: viper.GetString("form.username_field"),
+			Password: viper.GetString("form.password_field"),
+		},
+		formpost.UserData{Username: username, Password: password},
+	)
+
+	return err
+}
+
+func (provider *formPostProvider) SamlAssertion() (string, error) {
+	if provider.samlPayload == "" {
+		return "", errors.New("Not logged in to identity provider")
+	}
+
+	return provider.samlPayload, nil
+}
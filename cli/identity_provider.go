@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/redbubble/yak/saml"
+	log "github.com/sirupsen/logrus"
+)
+
+type IdentityProvider interface {
+	Authenticate() error
+	SamlAssertion() (string, error)
+}
+
+func GetIdentityProvider() (IdentityProvider, error) {
+	if UsingAssertionFile() {
+		return newAssertionFileProvider()
+	}
+
+	switch viper.GetString("login.provider") {
+	case "okta":
+		return newOktaProvider()
+	case "form":
+		return newFormPostProvider()
+	default:
+		return nil, fmt.Errorf("Unknown identity provider '%s'. Valid providers: [okta form]", viper.GetString("login.provider"))
+	}
+}
+
+func getLoginData() (saml.LoginData, error) {
+	provider, err := GetIdentityProvider()
+
+	if err != nil {
+		return saml.LoginData{}, err
+	}
+
+	err = provider.Authenticate()
+
+	if err != nil {
+		return saml.LoginData{}, err
+	}
+
+	samlPayload, err := provider.SamlAssertion()
+
+	if err != nil {
+		return saml.LoginData{}, err
+	}
+
+	samlResponse, err := saml.ParseResponse(samlPayload)
+
+	if err != nil {
+		return saml.LoginData{}, err
+	}
+	log.WithField("saml", samlResponse).Debug("identity_provider.go: SAML response from identity provider")
+
+	return saml.CreateLoginData(samlResponse, samlPayload), nil
+}
@@ -9,6 +9,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -94,7 +95,80 @@ func checkOktaSession(session *okta.OktaSession) bool {
 	return err == nil
 }
 
+// A SAML assertion is only good for a few minutes, but until it expires one
+// login can be used to assume any number of roles. Stop a little early so we
+// don't send AWS an assertion that expires on the way.
+const loginDataExpiryMargin = 30 * time.Second
+
+// Some IdPs don't say when their assertions expire, so assume they last as
+// long as Okta's do
+const loginDataDefaultLifetime = 5 * time.Minute
+
+var recentLogin struct {
+	sync.Mutex
+	data      saml.LoginData
+	loginTime time.Time
+}
+
 func GetLoginDataWithTimeout() (saml.LoginData, error) {
+	recentLogin.Lock()
+	defer recentLogin.Unlock()
+
+	if recentLoginValid() {
+		log.Debug("login.go: Reusing login data from earlier")
+		return recentLogin.data, nil
+	}
+
+	data, err := getLoginDataWithTimeout()
+
+	if err != nil {
+		return saml.LoginData{}, err
+	}
+
+	recentLogin.data = data
+	recentLogin.loginTime = time.Now()
+
+	return data, nil
+}
+
+// getLoginDataFor only logs in if it's allowed to prompt. An assertion file
+// doesn't need any prompting, and neither does reusing a recent login.
+func getLoginDataFor(prompt bool) (saml.LoginData, error) {
+	if prompt || UsingAssertionFile() {
+		return GetLoginDataWithTimeout()
+	}
+
+	recentLogin.Lock()
+	defer recentLogin.Unlock()
+
+	if recentLoginValid() {
+		log.Debug("login.go: Reusing login data from earlier")
+		return recentLogin.data, nil
+	}
+
+	return saml.LoginData{}, ErrPromptRequired
+}
+
+// recentLoginValid needs recentLogin to be locked
+func recentLoginValid() bool {
+	if recentLogin.loginTime.IsZero() {
+		return false
+	}
+
+	return time.Now().Before(loginDataReusableUntil(recentLogin.data, recentLogin.loginTime))
+}
+
+func loginDataReusableUntil(data saml.LoginData, loginTime time.Time) time.Time {
+	expiry := data.NotOnOrAfter
+
+	if expiry.IsZero() {
+		expiry = loginTime.Add(loginDataDefaultLifetime)
+	}
+
+	return expiry.Add(-loginDataExpiryMargin)
+}
+
+func getLoginDataWithTimeout() (saml.LoginData, error) {
 	errorChannel := make(chan error)
 	resultChannel := make(chan saml.LoginData)
 
@@ -129,7 +203,20 @@ func GetLoginDataWithTimeout() (saml.LoginData, error) {
 	}
 }
 
-func getLoginData() (saml.LoginData, error) {
+type oktaProvider struct {
+	session *okta.OktaSession
+}
+
+func newOktaProvider() (*oktaProvider, error) {
+	if oktaDomain() == "" || viper.GetString("okta.aws_saml_endpoint") == "" {
+		return nil, errors.New(`An Okta domain and an AWS SAML Endpoint must be configured for yak to work.
+These can be configured either in the [okta] section of ~/.config/yak/config.toml or by passing the --okta-domain and --okta-aws-saml-endpoint arguments.`)
+	}
+
+	return &oktaProvider{}, nil
+}
+
+func (provider *oktaProvider) Authenticate() error {
 	session, gotSession := getOktaSessionFromCache()
 
 	if gotSession && session.ExpiresAt.After(time.Now()) {
@@ -147,13 +234,13 @@ func getLoginData() (saml.LoginData, error) {
 		log.Infof("Okta session not in cache or no longer valid, re-authenticating")
 
 		if viper.GetBool("cache.cache_only") {
-			return saml.LoginData{}, errors.New("Could not find credentials in cache and --cache-only specified. Run `yak <role>` to remedy.")
+			return errors.New("Could not find credentials in cache and --cache-only specified. Run `yak <role>` to remedy.")
 		}
 
 		authResponse, err = promptLogin()
 
 		if err != nil {
-			return saml.LoginData{}, err
+			return err
 
 		}
 
@@ -161,36 +248,34 @@ func getLoginData() (saml.LoginData, error) {
 			selectedFactor, err := chooseMFA(authResponse)
 
 			if err != nil {
-				return saml.LoginData{}, err
+				return err
 			}
 
 			authResponse, err = promptMFA(selectedFactor, authResponse.StateToken)
 
 			if err != nil {
-				return saml.LoginData{}, err
+				return err
 			}
 		}
 
 		session, err = getOktaSession(authResponse)
 		if err != nil {
-			return saml.LoginData{}, err
+			return err
 		}
 
 	}
 
-	samlPayload, err := okta.AwsSamlLogin(oktaDomain(), viper.GetString("okta.aws_saml_endpoint"), *session)
-	if err != nil {
-		return saml.LoginData{}, err
-	}
+	provider.session = session
 
-	samlResponse, err := saml.ParseResponse(samlPayload)
+	return nil
+}
 
-	if err != nil {
-		return saml.LoginData{}, err
+func (provider *oktaProvider) SamlAssertion() (string, error) {
+	if provider.session == nil {
+		return "", errors.New("Not logged in to Okta")
 	}
-	log.WithField("saml", samlResponse).Debug("okta.go: SAML response from Okta")
 
-	return saml.CreateLoginData(samlResponse, samlPayload), nil
+	return okta.AwsSamlLogin(oktaDomain(), viper.GetString("okta.aws_saml_endpoint"), *provider.session)
 }
 
 func chooseMFA(authResponse okta.OktaAuthResponse) (okta.AuthResponseFactor, error) {
@@ -314,13 +399,13 @@ func promptMFA(factor okta.AuthResponseFactor, stateToken string) (okta.OktaAuth
 
 		switch factor.FactorType {
 		case "push":
-			authResponse, err = okta.VerifyPush(factor.Links.VerifyLink.Href, okta.PushRequest{stateToken})
+			authResponse, err = okta.VerifyPush(factor.Links.VerifyLink.Href, okta.PushRequest{StateToken: stateToken})
 		case "token:software:totp":
 			passCode, _ := promptOrPinentry(fmt.Sprintf("Okta MFA token (from %s): ", okta.TotpFactorName(factor.Provider)), false)
-			authResponse, err = okta.VerifyTotp(factor.Links.VerifyLink.Href, okta.TotpRequest{stateToken, passCode})
+			authResponse, err = okta.VerifyTotp(factor.Links.VerifyLink.Href, okta.TotpRequest{StateToken: stateToken, PassCode: passCode})
 		case "token:hardware":
 			passCode, _ := promptOrPinentry(fmt.Sprintf("Okta MFA token (from %s): ", okta.TotpFactorName(factor.Provider)), false)
-			authResponse, err = okta.VerifyTotp(factor.Links.VerifyLink.Href, okta.TotpRequest{stateToken, passCode})
+			authResponse, err = okta.VerifyTotp(factor.Links.VerifyLink.Href, okta.TotpRequest{StateToken: stateToken, PassCode: passCode})
 		default:
 			err := errors.New("Unknown factor type selected. Exiting.")
 			return authResponse, err
@@ -373,7 +458,7 @@ func promptLogin() (okta.OktaAuthResponse, error) {
 			}
 		}
 
-		authResponse, err = okta.Authenticate(oktaDomain(), okta.UserData{username, password})
+		authResponse, err = okta.Authenticate(oktaDomain(), okta.UserData{Username: username, Password: password})
 
 		if authResponse.YakStatusCode == okta.YAK_STATUS_UNAUTHORISED && retries < maxLoginRetries && !envPassword {
 			fmt.Fprintln(os.Stderr, "Sorry, try again.")
@@ -385,7 +470,36 @@ func promptLogin() (okta.OktaAuthResponse, error) {
 	return authResponse, err
 }
 
+// GetRoles lists the roles we can assume with SAML, logging in to find out
+// if they aren't cached
+func GetRoles() ([]saml.LoginRole, error) {
+	roles, gotRoles := GetRolesFromCache()
+
+	// An assertion passed in explicitly should win over whatever Okta told us last time
+	if !gotRoles || UsingAssertionFile() {
+		log.Infof("Role list not in cache, grabbing from AWS")
+		loginData, err := GetLoginDataWithTimeout()
+
+		if err != nil {
+			return nil, err
+		}
+
+		CacheLoginRoles(loginData.Roles)
+		cache.Export()
+
+		roles = (loginData.Roles)
+	}
+
+	return roles, nil
+}
+
 func CacheLoginRoles(roles []saml.LoginRole) {
+	// The cached role list is what Okta gives us; an assertion file may be for
+	// another IdP or user entirely, so it shouldn't replace that list
+	if UsingAssertionFile() {
+		return
+	}
+
 	data := []string{}
 
 	for _, role := range roles {
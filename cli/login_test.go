@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redbubble/yak/saml"
+)
+
+func TestLoginDataReusableUntil(t *testing.T) {
+	loginTime := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	scenarios := []struct {
+		description  string
+		notOnOrAfter time.Time
+		expected     time.Time
+	}{
+		{"Reuses the assertion until just before it expires", loginTime.Add(time.Hour), loginTime.Add(59*time.Minute + 30*time.Second)},
+		{"Falls back to a few minutes when the assertion doesn't say", time.Time{}, loginTime.Add(4*time.Minute + 30*time.Second)},
+	}
+
+	for _, scenario := range scenarios {
+		until := loginDataReusableUntil(saml.LoginData{NotOnOrAfter: scenario.notOnOrAfter}, loginTime)
+
+		if !until.Equal(scenario.expected) {
+			t.Log("---------------")
+			t.Log(scenario.description)
+			t.Logf("Expected: %s", scenario.expected)
+			t.Logf("Got: %s", until)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+var ambiguousRoleErrorMessage = `'%s' matches more than one role:
+
+%s
+Please use one of them, or a more specific pattern.`
+
+// IsRoleSelector tells whether a name picks out roles by pattern rather than
+// naming one: '@group' for the members of an alias group, '/regex/', or a
+// glob like 'prod-*'
+func IsRoleSelector(name string) bool {
+	return strings.HasPrefix(name, "@") || isRegexSelector(name) || strings.ContainsAny(name, "*?")
+}
+
+// SelectRoles finds the aliases and role ARNs a selector refers to. Only
+// patterns need the list of roles, so a group of plain names won't log in.
+func SelectRoles(selector string) ([]string, error) {
+	if !IsRoleSelector(selector) {
+		return []string{selector}, nil
+	}
+
+	aliases, err := GetAliases()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	getCandidates := func() ([]string, error) {
+		if candidates != nil {
+			return candidates, nil
+		}
+
+		roles, err := GetRoles()
+
+		if err != nil {
+			return nil, err
+		}
+
+		for name := range aliases {
+			candidates = append(candidates, name)
+		}
+
+		for _, role := range roles {
+			candidates = append(candidates, role.RoleArn)
+		}
+
+		return candidates, nil
+	}
+
+	matches, err := selectNames(selector, getCandidates, map[string]bool{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// An alias and the role it points at are the same thing, so only keep
+	// the alias, unless the role was asked for by name
+	aliased := map[string]bool{}
+	for _, name := range matches {
+		if alias, ok := aliases[name]; ok && !alias.Chained() {
+			aliased[alias.Arn] = true
+		}
+	}
+
+	names := []string{}
+	seen := map[string]bool{}
+	for _, name := range matches {
+		if _, isAlias := aliases[name]; (isAlias || !aliased[name]) && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("No roles or aliases match '%s'. Run 'yak --list-roles' to see which ones you can use.", selector)
+	}
+
+	return names, nil
+}
+
+// GetGroups reads the alias groups set up in the [group] section of the config
+func GetGroups() map[string][]string {
+	groups := map[string][]string{}
+
+	for name := range viper.GetStringMap("group") {
+		groups[name] = viper.GetStringSlice("group." + name + ".members")
+	}
+
+	return groups
+}
+
+func selectRole(selector string) (string, error) {
+	names, err := SelectRoles(selector)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(names) > 1 {
+		list := strings.Builder{}
+		for _, name := range names {
+			list.WriteString("    " + name + "\n")
+		}
+
+		return "", fmt.Errorf(ambiguousRoleErrorMessage, selector, list.String())
+	}
+
+	return names[0], nil
+}
+
+func selectNames(selector string, getCandidates func() ([]string, error), visited map[string]bool) ([]string, error) {
+	if strings.HasPrefix(selector, "@") {
+		return selectGroup(strings.TrimPrefix(selector, "@"), getCandidates, visited)
+	}
+
+	candidates, err := getCandidates()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if isRegexSelector(selector) {
+		matcher, err := regexp.Compile(selector[1 : len(selector)-1])
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid role pattern '%s': %w", selector, err)
+		}
+
+		return matchNames(matcher, candidates), nil
+	}
+
+	return MatchRoleNames(selector, candidates), nil
+}
+
+func selectGroup(group string, getCandidates func() ([]string, error), visited map[string]bool) ([]string, error) {
+	// Viper lowercases keys, so group names have to be looked up the same way
+	key := "group." + strings.ToLower(group)
+
+	if !viper.IsSet(key) {
+		return nil, fmt.Errorf("There's no alias group called '%s'", group)
+	}
+
+	if visited[key] {
+		return nil, fmt.Errorf("Alias group '%s' includes itself", group)
+	}
+
+	visited[key] = true
+	defer delete(visited, key)
+
+	names := []string{}
+
+	for _, member := range viper.GetStringSlice(key + ".members") {
+		if !IsRoleSelector(member) {
+			names = append(names, member)
+			continue
+		}
+
+		matches, err := selectNames(member, getCandidates, visited)
+
+		if err != nil {
+			return nil, err
+		}
+
+		names = append(names, matches...)
+	}
+
+	return names, nil
+}
+
+// MatchRoleNames picks out the candidates matching a glob pattern, where *
+// matches anything at all, so that e.g. '*:role/admin' matches across accounts
+func MatchRoleNames(pattern string, candidates []string) []string {
+	return matchNames(globPattern(pattern), candidates)
+}
+
+func matchNames(matcher *regexp.Regexp, candidates []string) []string {
+	matches := []string{}
+	seen := map[string]bool{}
+
+	for _, candidate := range candidates {
+		if !seen[candidate] && matcher.MatchString(candidate) {
+			matches = append(matches, candidate)
+			seen[candidate] = true
+		}
+	}
+
+	sort.Strings(matches)
+
+	return matches
+}
+
+func isRegexSelector(name string) bool {
+	return len(name) > 2 && strings.HasPrefix(name, "/") && strings.HasSuffix(name, "/")
+}
+
+func globPattern(pattern string) *regexp.Regexp {
+	expression := strings.Builder{}
+	expression.WriteString("^")
+
+	for _, char := range pattern {
+		switch char {
+		case '*':
+			expression.WriteString(".*")
+		case '?':
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+
+	expression.WriteString("$")
+
+	return regexp.MustCompile(expression.String())
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestMatchRoleNames(t *testing.T) {
+	candidates := []string{
+		"prod-admin",
+		"prod-readonly",
+		"staging-admin",
+		"arn:aws:iam::123456789012:role/admin",
+		"arn:aws:iam::210987654321:role/admin",
+		"arn:aws:iam::210987654321:role/path/readonly",
+	}
+
+	scenarios := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"prod-*", []string{"prod-admin", "prod-readonly"}},
+		{"*-admin", []string{"prod-admin", "staging-admin"}},
+		{"*:role/admin", []string{"arn:aws:iam::123456789012:role/admin", "arn:aws:iam::210987654321:role/admin"}},
+		{"*readonly", []string{"arn:aws:iam::210987654321:role/path/readonly", "prod-readonly"}},
+		{"prod-admi?", []string{"prod-admin"}},
+		{"prod-admin", []string{"prod-admin"}},
+		{"prod.admin", []string{}},
+		{"llama", []string{}},
+	}
+
+	for _, scenario := range scenarios {
+		subject := MatchRoleNames(scenario.pattern, candidates)
+
+		if !reflect.DeepEqual(subject, scenario.expected) {
+			t.Log("---------------")
+			t.Logf("Did not match '%s' correctly", scenario.pattern)
+			t.Logf("Expected: %v", scenario.expected)
+			t.Logf("Got: %v", subject)
+			t.Fail()
+		}
+	}
+}
+
+func TestSelectNames(t *testing.T) {
+	viper.Set("group", map[string]interface{}{
+		"prod":    map[string]interface{}{"members": []string{"prod-admin", "prod-readonly"}},
+		"admins":  map[string]interface{}{"members": []string{"@prod", "*:role/admin"}},
+		"forever": map[string]interface{}{"members": []string{"@forever"}},
+	})
+	defer viper.Set("group", nil)
+
+	candidates := func() ([]string, error) {
+		return []string{"prod-admin", "staging-admin", "arn:aws:iam::123456789012:role/admin"}, nil
+	}
+
+	scenarios := []struct {
+		selector string
+		expected []string
+	}{
+		{"@prod", []string{"prod-admin", "prod-readonly"}},
+		{"@admins", []string{"prod-admin", "prod-readonly", "arn:aws:iam::123456789012:role/admin"}},
+		{"/-admin$/", []string{"prod-admin", "staging-admin"}},
+		{"/admin/", []string{"arn:aws:iam::123456789012:role/admin", "prod-admin", "staging-admin"}},
+		{"staging-*", []string{"staging-admin"}},
+	}
+
+	for _, scenario := range scenarios {
+		subject, err := selectNames(scenario.selector, candidates, map[string]bool{})
+
+		if err != nil || !reflect.DeepEqual(subject, scenario.expected) {
+			t.Log("---------------")
+			t.Logf("Did not select '%s' correctly", scenario.selector)
+			t.Logf("Expected: %v", scenario.expected)
+			t.Logf("Got: %v (error: %v)", subject, err)
+			t.Fail()
+		}
+	}
+
+	for _, selector := range []string{"@forever", "@nothing", "/[/"} {
+		if _, err := selectNames(selector, candidates, map[string]bool{}); err == nil {
+			t.Log("---------------")
+			t.Logf("Selected '%s' without error", selector)
+			t.Fail()
+		}
+	}
+}
+
+func TestSelectRole(t *testing.T) {
+	viper.Set("group", map[string]interface{}{
+		"one":  map[string]interface{}{"members": []string{"arn:aws:iam::123456789012:role/llama"}},
+		"many": map[string]interface{}{"members": []string{"arn:aws:iam::123456789012:role/llama", "arn:aws:iam::123456789012:role/alpaca"}},
+	})
+	defer viper.Set("group", nil)
+
+	role, err := ResolveRole("@one")
+
+	if err != nil || role.Arn != "arn:aws:iam::123456789012:role/llama" {
+		t.Log("---------------")
+		t.Log("A group with one member did not select that role")
+		t.Log("Expected: arn:aws:iam::123456789012:role/llama")
+		t.Logf("Got: %+v (error: %v)", role, err)
+		t.Fail()
+	}
+
+	_, err = ResolveRole("@many")
+
+	if err == nil || !strings.Contains(err.Error(), "role/alpaca") || !strings.Contains(err.Error(), "role/llama") {
+		t.Log("---------------")
+		t.Log("A group with several members did not say which roles it could be")
+		t.Log("Expected: an error listing role/alpaca and role/llama")
+		t.Logf("Got: %v", err)
+		t.Fail()
+	}
+}
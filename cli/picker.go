@@ -0,0 +1,284 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const maxPickerLines = 15
+
+type PickerItem struct {
+	Name        string
+	Description string
+}
+
+type pickerMatch struct {
+	item  PickerItem
+	score int
+}
+
+// PickerAvailable tells whether there's a terminal to run the picker on; it
+// draws on stderr, so that stdout can still be captured
+func PickerAvailable() bool {
+	return terminal.IsTerminal(int(os.Stdin.Fd())) && terminal.IsTerminal(int(os.Stderr.Fd()))
+}
+
+// PickRole lets the user choose one of the items, typing to narrow them down
+// and using the arrow keys to move between them
+func PickRole(items []PickerItem) (string, error) {
+	if len(items) == 0 {
+		return "", errors.New("There are no roles to pick from. Run 'yak --list-roles' to fetch them.")
+	}
+
+	stdin := int(os.Stdin.Fd())
+	state, err := terminal.MakeRaw(stdin)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer terminal.Restore(stdin, state)
+
+	width, height, err := terminal.GetSize(int(os.Stderr.Fd()))
+
+	if err != nil || width == 0 || height == 0 {
+		width, height = 80, 24
+	}
+
+	picker := picker{
+		items:    items,
+		out:      os.Stderr,
+		width:    width,
+		maxLines: max(min(maxPickerLines, height-1), 1),
+	}
+
+	return picker.run(os.Stdin)
+}
+
+type picker struct {
+	items    []PickerItem
+	out      io.Writer
+	width    int
+	maxLines int
+
+	query    []rune
+	matches  []pickerMatch
+	selected int
+}
+
+func (picker *picker) run(in io.Reader) (string, error) {
+	picker.filter()
+	picker.draw()
+	defer picker.clear()
+
+	buffer := make([]byte, 64)
+
+	for {
+		count, err := in.Read(buffer)
+
+		if err != nil {
+			return "", err
+		}
+
+		key := buffer[:count]
+
+		switch {
+		case string(key) == "\r" || string(key) == "\n":
+			if len(picker.matches) == 0 {
+				continue
+			}
+
+			return picker.matches[picker.selected].item.Name, nil
+		case string(key) == "\x03" || string(key) == "\x04" || string(key) == "\x1b":
+			return "", errors.New("No role picked")
+		case string(key) == "\x1b[A" || string(key) == "\x1bOA" || string(key) == "\x10":
+			picker.move(-1)
+		case string(key) == "\x1b[B" || string(key) == "\x1bOB" || string(key) == "\x0e" || string(key) == "\t":
+			picker.move(1)
+		case string(key) == "\x7f" || string(key) == "\x08":
+			if len(picker.query) > 0 {
+				picker.query = picker.query[:len(picker.query)-1]
+				picker.filter()
+			}
+		case string(key) == "\x15":
+			picker.query = nil
+			picker.filter()
+		case key[0] == '\x1b':
+			// Some other escape sequence we don't handle
+		default:
+			for len(key) > 0 {
+				char, size := utf8.DecodeRune(key)
+				key = key[size:]
+
+				if unicode.IsPrint(char) {
+					picker.query = append(picker.query, char)
+				}
+			}
+
+			picker.filter()
+		}
+
+		picker.draw()
+	}
+}
+
+func (picker *picker) move(offset int) {
+	if len(picker.matches) == 0 {
+		return
+	}
+
+	picker.selected = (picker.selected + offset + len(picker.matches)) % len(picker.matches)
+}
+
+func (picker *picker) filter() {
+	picker.matches = fuzzyFilter(string(picker.query), picker.items)
+	picker.selected = 0
+}
+
+// draw writes the list below the prompt, then moves back up to the prompt
+// so the cursor sits where the user is typing
+func (picker *picker) draw() {
+	lines := []string{}
+
+	start := 0
+	if picker.selected >= picker.maxLines {
+		start = picker.selected - picker.maxLines + 1
+	}
+
+	for index := start; index < len(picker.matches) && index < start+picker.maxLines; index++ {
+		item := picker.matches[index].item
+		line := item.Name
+
+		if item.Description != "" {
+			line += "  " + item.Description
+		}
+
+		line = truncate("  "+line, picker.width-1)
+
+		if index == picker.selected {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+
+		lines = append(lines, line)
+	}
+
+	output := strings.Builder{}
+	output.WriteString("\r\x1b[J")
+
+	for _, line := range lines {
+		output.WriteString("\r\n" + line)
+	}
+
+	if len(lines) > 0 {
+		output.WriteString(fmt.Sprintf("\x1b[%dA", len(lines)))
+	}
+
+	output.WriteString(fmt.Sprintf("\r%d/%d > %s", len(picker.matches), len(picker.items), string(picker.query)))
+
+	fmt.Fprint(picker.out, output.String())
+}
+
+func (picker *picker) clear() {
+	fmt.Fprint(picker.out, "\r\x1b[J")
+}
+
+// fuzzyFilter keeps the items whose names contain the query's characters in
+// order, best matches first
+func fuzzyFilter(query string, items []PickerItem) []pickerMatch {
+	matches := []pickerMatch{}
+
+	for _, item := range items {
+		if score, ok := fuzzyScore(query, item.Name); ok {
+			matches = append(matches, pickerMatch{item: item, score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	return matches
+}
+
+// fuzzyScore favours matches that run together and that start at the
+// beginning of a word, so 'pa' ranks 'prod-admin' and 'ProdAdmin' above
+// 'pineapple'
+func fuzzyScore(query string, candidate string) (int, bool) {
+	queryRunes := []rune(strings.ToLower(query))
+	originalRunes := []rune(candidate)
+
+	candidateRunes := make([]rune, len(originalRunes))
+	for index, char := range originalRunes {
+		candidateRunes[index] = unicode.ToLower(char)
+	}
+
+	score := 0
+	position := 0
+	previous := -2
+
+	for _, char := range queryRunes {
+		found := false
+
+		for ; position < len(candidateRunes); position++ {
+			if candidateRunes[position] != char {
+				continue
+			}
+
+			score++
+
+			if position == previous+1 {
+				score += 5
+			}
+
+			if position == 0 || strings.ContainsRune("/:-_. @", candidateRunes[position-1]) ||
+				(unicode.IsUpper(originalRunes[position]) && unicode.IsLower(originalRunes[position-1])) {
+				score += 3
+			}
+
+			previous = position
+			position++
+			found = true
+			break
+		}
+
+		if !found {
+			return 0, false
+		}
+	}
+
+	return score, true
+}
+
+func truncate(line string, width int) string {
+	runes := []rune(line)
+
+	if width < 1 || len(runes) <= width {
+		return line
+	}
+
+	return string(runes[:width-1]) + "…"
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func max(a int, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFuzzyFilter(t *testing.T) {
+	items := []PickerItem{
+		{Name: "pineapple"},
+		{Name: "prod-admin"},
+		{Name: "staging"},
+		{Name: "arn:aws:iam::123456789012:role/ProdAdmin"},
+	}
+
+	matches := fuzzyFilter("", items)
+
+	if len(matches) != len(items) || matches[0].item.Name != "pineapple" {
+		t.Log("---------------")
+		t.Log("An empty query did not keep everything in order")
+		t.Logf("Expected: all %d items, starting with pineapple", len(items))
+		t.Logf("Got: %v", matches)
+		t.Fail()
+	}
+
+	matches = fuzzyFilter("pa", items)
+	names := []string{}
+
+	for _, match := range matches {
+		names = append(names, match.item.Name)
+	}
+
+	expected := "prod-admin,arn:aws:iam::123456789012:role/ProdAdmin,pineapple"
+
+	if strings.Join(names, ",") != expected {
+		t.Log("---------------")
+		t.Log("Matches at the start of words did not rank first, or non-matches were kept")
+		t.Logf("Expected: %s", expected)
+		t.Logf("Got: %s", strings.Join(names, ","))
+		t.Fail()
+	}
+
+	matches = fuzzyFilter("PRODADMIN", items)
+
+	if len(matches) != 2 {
+		t.Log("---------------")
+		t.Log("Matching did not ignore case")
+		t.Log("Expected: 2 matches")
+		t.Logf("Got: %v", matches)
+		t.Fail()
+	}
+}
+
+func TestPickerRun(t *testing.T) {
+	items := []PickerItem{{Name: "prod-admin"}, {Name: "prod-readonly"}, {Name: "staging"}}
+
+	typed := &picker{items: items, out: &bytes.Buffer{}, width: 80, maxLines: 10}
+	name, err := typed.run(&keyReader{keys: []string{"p", "r", "o", "d", "\x1b[B", "\r"}})
+
+	if err != nil || name != "prod-readonly" {
+		t.Log("---------------")
+		t.Log("Typing and the arrow keys did not pick the right role")
+		t.Log("Expected: prod-readonly")
+		t.Logf("Got: %s (error: %v)", name, err)
+		t.Fail()
+	}
+
+	cancelled := &picker{items: items, out: &bytes.Buffer{}, width: 80, maxLines: 10}
+	_, err = cancelled.run(&keyReader{keys: []string{"s", "\x1b"}})
+
+	if err == nil {
+		t.Log("---------------")
+		t.Log("Escape did not cancel the picker")
+		t.Fail()
+	}
+}
+
+// keyReader gives one key per read, as a terminal in raw mode would
+type keyReader struct {
+	keys []string
+}
+
+func (reader *keyReader) Read(buffer []byte) (int, error) {
+	key := reader.keys[0]
+	reader.keys = reader.keys[1:]
+	return copy(buffer, key), nil
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// PrefixWriter labels each line written to it, so that output from several
+// commands at once can share a terminal. Writers sharing a mutex never
+// interleave partial lines.
+type PrefixWriter struct {
+	prefix []byte
+	out    io.Writer
+	mutex  *sync.Mutex
+	buffer []byte
+}
+
+func NewPrefixWriter(prefix string, out io.Writer, mutex *sync.Mutex) *PrefixWriter {
+	return &PrefixWriter{prefix: []byte(prefix), out: out, mutex: mutex}
+}
+
+func (writer *PrefixWriter) Write(data []byte) (int, error) {
+	writer.buffer = append(writer.buffer, data...)
+
+	for {
+		index := bytes.IndexByte(writer.buffer, '\n')
+
+		if index < 0 {
+			return len(data), nil
+		}
+
+		if err := writer.writeLine(writer.buffer[:index+1]); err != nil {
+			return 0, err
+		}
+
+		writer.buffer = writer.buffer[index+1:]
+	}
+}
+
+// Flush writes out anything left over that didn't end in a newline
+func (writer *PrefixWriter) Flush() error {
+	if len(writer.buffer) == 0 {
+		return nil
+	}
+
+	line := append(writer.buffer, '\n')
+	writer.buffer = nil
+
+	return writer.writeLine(line)
+}
+
+func (writer *PrefixWriter) writeLine(line []byte) error {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	_, err := writer.out.Write(append(append([]byte{}, writer.prefix...), line...))
+
+	return err
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	output := bytes.Buffer{}
+	mutex := sync.Mutex{}
+
+	llama := NewPrefixWriter("[llama] ", &output, &mutex)
+	alpaca := NewPrefixWriter("[alpaca] ", &output, &mutex)
+
+	llama.Write([]byte("first line\nsecond "))
+	alpaca.Write([]byte("interrupting\n"))
+	llama.Write([]byte("line\nno newline"))
+	llama.Flush()
+	alpaca.Flush()
+
+	expected := "[llama] first line\n[alpaca] interrupting\n[llama] second line\n[llama] no newline\n"
+
+	if output.String() != expected {
+		t.Log("---------------")
+		t.Log("Did not prefix output lines correctly")
+		t.Logf("Expected: %q", expected)
+		t.Logf("Got: %q", output.String())
+		t.Fail()
+	}
+}
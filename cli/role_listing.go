@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/spf13/viper"
+
+	"github.com/redbubble/yak/saml"
+)
+
+type RoleDescription struct {
+	RoleArn      string
+	PrincipalArn string
+	AccountId    string
+	AccountName  string
+	RoleName     string
+	Aliases      []string
+	// When the cached credentials for the role expire, if there are any
+	CachedUntil *time.Time
+}
+
+// DescribeRoles fills in what we know about each role, sorted by account and
+// then by role name. Only aliases pointing straight at a role are counted as
+// its aliases; chained ones are a different role altogether.
+func DescribeRoles(roles []saml.LoginRole, aliases map[string]Role, accountNames map[string]string) []RoleDescription {
+	aliasesByArn := map[string][]string{}
+
+	for name, alias := range aliases {
+		if !alias.Chained() {
+			aliasesByArn[alias.Arn] = append(aliasesByArn[alias.Arn], name)
+		}
+	}
+
+	descriptions := []RoleDescription{}
+
+	for _, role := range roles {
+		description := RoleDescription{
+			RoleArn:      role.RoleArn,
+			PrincipalArn: role.PrincipalArn,
+			RoleName:     role.RoleArn,
+			Aliases:      aliasesByArn[role.RoleArn],
+			CachedUntil:  cachedCredentialsExpiration(role.RoleArn),
+		}
+
+		if parsed, err := arn.Parse(role.RoleArn); err == nil {
+			description.AccountId = parsed.AccountID
+			description.RoleName = roleName(parsed.Resource)
+		}
+
+		description.AccountName = accountNames[description.AccountId]
+
+		if description.Aliases == nil {
+			description.Aliases = []string{}
+		}
+		sort.Strings(description.Aliases)
+
+		descriptions = append(descriptions, description)
+	}
+
+	sort.SliceStable(descriptions, func(i, j int) bool {
+		left := strings.ToLower(descriptions[i].AccountLabel())
+		right := strings.ToLower(descriptions[j].AccountLabel())
+
+		if left != right {
+			return left < right
+		}
+
+		return descriptions[i].RoleName < descriptions[j].RoleName
+	})
+
+	return descriptions
+}
+
+// AccountLabel names the role's account for people, keeping the ID alongside
+// any name so it can still be told apart
+func (description RoleDescription) AccountLabel() string {
+	if description.AccountName == "" {
+		return description.AccountId
+	}
+
+	return description.AccountName + " (" + description.AccountId + ")"
+}
+
+func cachedCredentialsExpiration(roleArn string) *time.Time {
+	creds := getAssumedRoleFromCache(samlRoleCacheKey(roleArn, viper.GetInt64("aws.session_duration")), 0)
+
+	if creds == nil || creds.Credentials == nil || creds.Credentials.Expiration == nil {
+		return nil
+	}
+
+	if !creds.Credentials.Expiration.After(time.Now()) {
+		return nil
+	}
+
+	return creds.Credentials.Expiration
+}
+
+// roleName drops the 'role/' prefix from an ARN's resource, keeping any path
+func roleName(resource string) string {
+	return strings.TrimPrefix(resource, "role/")
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/redbubble/yak/saml"
+)
+
+func TestDescribeRoles(t *testing.T) {
+	roles := []saml.LoginRole{
+		{RoleArn: "arn:aws:iam::222222222222:role/path/deploy", PrincipalArn: "arn:aws:iam::222222222222:saml-provider/okta"},
+		{RoleArn: "arn:aws:iam::111111111111:role/readonly", PrincipalArn: "arn:aws:iam::111111111111:saml-provider/okta"},
+		{RoleArn: "arn:aws:iam::111111111111:role/admin", PrincipalArn: "arn:aws:iam::111111111111:saml-provider/okta"},
+	}
+
+	aliases := map[string]Role{
+		"prod":    {Arn: "arn:aws:iam::111111111111:role/admin"},
+		"ops":     {Arn: "arn:aws:iam::111111111111:role/admin"},
+		"chained": {Arn: "arn:aws:iam::111111111111:role/admin", Source: "prod"},
+	}
+
+	descriptions := DescribeRoles(roles, aliases, map[string]string{"222222222222": "apps", "111111111111": "production"})
+
+	expected := []RoleDescription{
+		{
+			RoleArn:      "arn:aws:iam::222222222222:role/path/deploy",
+			PrincipalArn: "arn:aws:iam::222222222222:saml-provider/okta",
+			AccountId:    "222222222222",
+			AccountName:  "apps",
+			RoleName:     "path/deploy",
+			Aliases:      []string{},
+		},
+		{
+			RoleArn:      "arn:aws:iam::111111111111:role/admin",
+			PrincipalArn: "arn:aws:iam::111111111111:saml-provider/okta",
+			AccountId:    "111111111111",
+			AccountName:  "production",
+			RoleName:     "admin",
+			Aliases:      []string{"ops", "prod"},
+		},
+		{
+			RoleArn:      "arn:aws:iam::111111111111:role/readonly",
+			PrincipalArn: "arn:aws:iam::111111111111:saml-provider/okta",
+			AccountId:    "111111111111",
+			AccountName:  "production",
+			RoleName:     "readonly",
+			Aliases:      []string{},
+		},
+	}
+
+	if !reflect.DeepEqual(descriptions, expected) {
+		t.Log("---------------")
+		t.Log("Roles were not grouped by account name, with the aliases pointing straight at them")
+		t.Logf("Expected: %+v", expected)
+		t.Logf("Got: %+v", descriptions)
+		t.Fail()
+	}
+
+	descriptions = DescribeRoles(roles[:1], aliases, map[string]string{})
+
+	if label := descriptions[0].AccountLabel(); label != "222222222222" {
+		t.Log("---------------")
+		t.Log("An account without a name was not labelled by its ID alone")
+		t.Log("Expected: 222222222222")
+		t.Logf("Got: %s", label)
+		t.Fail()
+	}
+
+	if label := expected[0].AccountLabel(); label != "apps (222222222222)" {
+		t.Log("---------------")
+		t.Log("An account with a name was not labelled by both")
+		t.Log("Expected: apps (222222222222)")
+		t.Logf("Got: %s", label)
+		t.Fail()
+	}
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type Shell struct {
+	Command []string
+	Env     map[string]string
+	cleanup []string
+}
+
+// UserShell finds the shell the user normally uses
+func UserShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+
+	if runtime.GOOS == "windows" {
+		if shell := os.Getenv("COMSPEC"); shell != "" {
+			return shell
+		}
+
+		return "cmd.exe"
+	}
+
+	return "/bin/sh"
+}
+
+// PromptPrefix builds a prompt fragment showing the role and how long its
+// credentials have left, worked out by the shell each time it's shown
+func PromptPrefix(name string, expiration time.Time) string {
+	remaining := fmt.Sprintf(
+		`$(r=$(( (%d - $(date +%%s)) / 60 )); if [ $r -ge 60 ]; then echo $((r / 60))h$((r %% 60))m; elif [ $r -gt 0 ]; then echo ${r}m; else echo expired; fi)`,
+		expiration.Unix(),
+	)
+
+	return fmt.Sprintf("[%s %s] ", name, remaining)
+}
+
+// NewShell works out how to start an interactive shell with promptPrefix
+// in front of its usual prompt. Shells read their prompt from startup files
+// that would clobber a PS1 set in the environment, so bash and zsh get
+// startup files of their own that load the user's ones first.
+func NewShell(shell string, promptPrefix string) (*Shell, error) {
+	subject := &Shell{Command: []string{shell}, Env: map[string]string{}}
+
+	if promptPrefix == "" {
+		return subject, nil
+	}
+
+	switch strings.TrimSuffix(filepath.Base(shell), ".exe") {
+	case "bash":
+		rcFile, err := writeTempFile("yak-bashrc-", bashRc(promptPrefix))
+
+		if err != nil {
+			return nil, err
+		}
+
+		subject.cleanup = append(subject.cleanup, rcFile)
+		subject.Command = append(subject.Command, "--rcfile", rcFile, "-i")
+	case "zsh":
+		dir, err := ioutil.TempDir("", "yak-zsh-")
+
+		if err != nil {
+			return nil, err
+		}
+
+		subject.cleanup = append(subject.cleanup, dir)
+
+		zdotdir := os.Getenv("ZDOTDIR")
+		if zdotdir == "" {
+			zdotdir = os.Getenv("HOME")
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(dir, ".zshenv"), []byte(zshEnv(zdotdir, dir)), 0600); err != nil {
+			return nil, err
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(dir, ".zshrc"), []byte(zshRc(zdotdir, promptPrefix)), 0600); err != nil {
+			return nil, err
+		}
+
+		subject.Env["ZDOTDIR"] = dir
+	case "sh", "dash", "ksh", "mksh":
+		subject.Env["PS1"] = promptPrefix + envOr("PS1", "$ ")
+	default:
+		log.Infof("Don't know how to change the prompt for %s; leaving it alone", shell)
+	}
+
+	return subject, nil
+}
+
+// Cleanup removes any startup files written for the shell
+func (shell *Shell) Cleanup() {
+	for _, path := range shell.cleanup {
+		os.RemoveAll(path)
+	}
+}
+
+func bashRc(promptPrefix string) string {
+	return fmt.Sprintf(`[ -f ~/.bashrc ] && . ~/.bashrc
+PS1=%s"$PS1"
+`, shellQuote(promptPrefix))
+}
+
+// zsh reads .zshenv and then .zshrc from ZDOTDIR; ours point it back at the
+// user's own files, only taking over again to load our .zshrc
+func zshEnv(zdotdir string, dir string) string {
+	return fmt.Sprintf(`ZDOTDIR=%s
+[ -f "$ZDOTDIR/.zshenv" ] && . "$ZDOTDIR/.zshenv"
+ZDOTDIR=%s
+`, shellQuote(zdotdir), shellQuote(dir))
+}
+
+func zshRc(zdotdir string, promptPrefix string) string {
+	return fmt.Sprintf(`ZDOTDIR=%s
+[ -f "$ZDOTDIR/.zshrc" ] && . "$ZDOTDIR/.zshrc"
+setopt PROMPT_SUBST
+PS1=%s"$PS1"
+`, shellQuote(zdotdir), shellQuote(promptPrefix))
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+func writeTempFile(pattern string, content string) (string, error) {
+	file, err := ioutil.TempFile("", pattern)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	if _, err := file.WriteString(content); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
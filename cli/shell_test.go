@@ -0,0 +1,108 @@
+//go:build !windows
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPromptPrefix(t *testing.T) {
+	scenarios := []struct {
+		expiration time.Time
+		expected   string
+	}{
+		{time.Now().Add(90*time.Minute + 30*time.Second), "[prod 1h30m] "},
+		{time.Now().Add(20*time.Minute + 30*time.Second), "[prod 20m] "},
+		{time.Now().Add(-time.Minute), "[prod expired] "},
+	}
+
+	for _, scenario := range scenarios {
+		output, err := exec.Command("sh", "-c", `printf '%s' "`+PromptPrefix("prod", scenario.expiration)+`"`).Output()
+
+		if err != nil {
+			t.Log("---------------")
+			t.Log("Got an error printing the prompt prefix")
+			t.Logf("Error: %v", err)
+			t.FailNow()
+		}
+
+		if string(output) != scenario.expected {
+			t.Log("---------------")
+			t.Log("Prompt prefix did not show the remaining time correctly")
+			t.Logf("Expected: %q", scenario.expected)
+			t.Logf("Got: %q", string(output))
+			t.Fail()
+		}
+	}
+}
+
+func TestNewShell(t *testing.T) {
+	shell, err := NewShell("/bin/bash", "")
+
+	if err != nil || strings.Join(shell.Command, " ") != "/bin/bash" || len(shell.Env) != 0 {
+		t.Log("---------------")
+		t.Log("Without a prompt prefix, the shell was not started as it is")
+		t.Log("Expected: /bin/bash")
+		t.Logf("Got: %+v (%v)", shell, err)
+		t.Fail()
+	}
+
+	shell, err = NewShell("/bin/bash", "[llama] ")
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("Got an error setting up bash")
+		t.Logf("Error: %v", err)
+		t.FailNow()
+	}
+
+	if len(shell.Command) != 4 || shell.Command[1] != "--rcfile" {
+		t.Log("---------------")
+		t.Log("bash was not given a startup file")
+		t.Log("Expected: /bin/bash --rcfile <file> -i")
+		t.Logf("Got: %v", shell.Command)
+		t.FailNow()
+	}
+
+	rcFile := shell.Command[2]
+	content, _ := ioutil.ReadFile(rcFile)
+
+	if !strings.Contains(string(content), `PS1='[llama] '"$PS1"`) {
+		t.Log("---------------")
+		t.Log("bash's startup file did not set the prompt")
+		t.Logf("Expected: PS1='[llama] '\"$PS1\"")
+		t.Logf("Got: %s", content)
+		t.Fail()
+	}
+
+	shell.Cleanup()
+
+	if _, err := os.Stat(rcFile); !os.IsNotExist(err) {
+		t.Log("---------------")
+		t.Logf("Cleanup did not remove %s", rcFile)
+		t.Fail()
+	}
+
+	shell, err = NewShell("/usr/bin/zsh", "[llama] ")
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("Got an error setting up zsh")
+		t.Logf("Error: %v", err)
+		t.FailNow()
+	}
+
+	defer shell.Cleanup()
+
+	if _, err := os.Stat(shell.Env["ZDOTDIR"] + "/.zshrc"); err != nil {
+		t.Log("---------------")
+		t.Log("zsh did not get a .zshrc in a ZDOTDIR of its own")
+		t.Logf("Error: %v", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/mitchellh/go-homedir"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/redbubble/yak/awsconfig"
+	"github.com/redbubble/yak/cli"
+)
+
+func writeAwsConfigCmd(cmd *cobra.Command, args []string) error {
+	configPath, err := homedir.Expand(viper.GetString("aws_config.file"))
+
+	if err != nil {
+		return err
+	}
+
+	if configPath == "" {
+		configPath, err = awsconfig.ConfigPath()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// This may need to log in, so get it out of the way before taking the lock
+	profiles, err := awsConfigProfiles()
+
+	if err != nil {
+		return err
+	}
+
+	unlock, err := awsconfig.LockFile(configPath)
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	existing, err := awsconfig.ReadFile(configPath)
+
+	if err != nil {
+		return err
+	}
+
+	unmanagedNames, err := awsconfig.UnmanagedProfileNames(existing)
+
+	if err != nil {
+		return err
+	}
+
+	unmanaged := map[string]bool{}
+	for _, name := range unmanagedNames {
+		unmanaged[name] = true
+	}
+
+	managedProfiles := []awsconfig.Profile{}
+	for _, profile := range profiles {
+		if unmanaged[profile.Name] {
+			log.Warnf("Profile %s is already defined outside yak's managed block in %s, skipping", profile.Name, configPath)
+			continue
+		}
+
+		managedProfiles = append(managedProfiles, profile)
+	}
+
+	updated, err := awsconfig.ReplaceManagedBlock(existing, awsconfig.RenderManagedBlock(managedProfiles, viper.GetString("aws_config.yak_command")))
+
+	if err != nil {
+		return err
+	}
+
+	if viper.GetBool("dry-run") {
+		fmt.Printf("--- %s\n+++ %s\n", configPath, configPath)
+		fmt.Print(awsconfig.Diff(existing, updated))
+		return nil
+	}
+
+	if updated == existing {
+		log.Infof("%s is already up to date", configPath)
+		return nil
+	}
+
+	log.Infof("Writing %d profiles to %s", len(managedProfiles), configPath)
+
+	return awsconfig.WriteFileAtomic(configPath, updated)
+}
+
+func awsConfigProfiles() ([]awsconfig.Profile, error) {
+	roles, err := cli.GetRoles()
+
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := cli.GetAliases()
+
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := []awsconfig.Profile{}
+	roleForProfile := map[string]string{}
+
+	addProfile := func(template string, role string, definition cli.Role) error {
+		fields, err := profileNameFields(definition.Arn)
+
+		if err != nil {
+			return err
+		}
+
+		fields["alias"] = definition.Alias
+
+		// An alias can name its own profile
+		if definition.Profile != "" {
+			template = definition.Profile
+		}
+
+		name, err := awsconfig.ProfileName(template, fields)
+
+		if err != nil {
+			return err
+		}
+
+		if other, ok := roleForProfile[name]; ok {
+			return fmt.Errorf("Both %s and %s would be written to profile %s; please use a more specific profile name template", other, role, name)
+		}
+
+		roleForProfile[name] = role
+
+		profiles = append(profiles, awsconfig.Profile{
+			Name:   name,
+			Role:   role,
+			Region: awsConfigRegion(name, definition.Region),
+		})
+
+		return nil
+	}
+
+	for _, role := range roles {
+		if err := addProfile(viper.GetString("aws_config.profile_name"), role.RoleArn, cli.Role{Arn: role.RoleArn}); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, alias := range aliases {
+		if err := addProfile(viper.GetString("aws_config.alias_profile_name"), name, alias); err != nil {
+			return nil, err
+		}
+	}
+
+	return profiles, nil
+}
+
+func profileNameFields(roleArn string) (map[string]string, error) {
+	parsed, err := arn.Parse(roleArn)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resourceParts := strings.Split(parsed.Resource, "/")
+
+	return map[string]string{
+		"account_id":    parsed.AccountID,
+		"account_alias": cli.AccountName(parsed.AccountID),
+		"role_name":     resourceParts[len(resourceParts)-1],
+		"partition":     parsed.Partition,
+	}, nil
+}
+
+func awsConfigRegion(profileName string, aliasRegion string) string {
+	// Viper lowercases keys, so profile names have to be looked up the same way
+	if region := viper.GetString("aws_config.regions." + strings.ToLower(profileName)); region != "" {
+		return region
+	}
+
+	if aliasRegion != "" {
+		return aliasRegion
+	}
+
+	return viper.GetString("aws_config.region")
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/redbubble/yak/cli"
+)
+
+// isCompletionCommand tells whether cobra is generating a completion script,
+// or asking for completions from one. Neither needs yak to be set up.
+func isCompletionCommand(cmd *cobra.Command) bool {
+	for ; cmd != nil; cmd = cmd.Parent() {
+		switch cmd.Name() {
+		case "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+			return true
+		}
+	}
+
+	return false
+}
+
+// After the role comes the command to run, which the shell knows best how to complete
+func completeRootArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+
+	return roleCompletions(toComplete, false), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeServeArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	return roleCompletions(toComplete, false), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeEach(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return roleCompletions(toComplete, true), cobra.ShellCompDirectiveNoFileComp
+}
+
+// roleCompletions suggests aliases and the roles in the cache, described by
+// their accounts. It must never log in: there's nowhere to prompt from here.
+func roleCompletions(toComplete string, includeGroups bool) []string {
+	completions := []string{}
+
+	add := func(name string, description string) {
+		if !strings.HasPrefix(name, toComplete) {
+			return
+		}
+
+		if description != "" {
+			name += "\t" + description
+		}
+
+		completions = append(completions, name)
+	}
+
+	aliases, err := cli.GetAliases()
+
+	if err == nil {
+		names := []string{}
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			description := aliases[name].Description
+
+			if description == "" {
+				description = accountDescription(aliases[name].Arn)
+			}
+
+			add(name, description)
+		}
+	}
+
+	if includeGroups {
+		groups := []string{}
+		for name := range cli.GetGroups() {
+			groups = append(groups, name)
+		}
+		sort.Strings(groups)
+
+		for _, name := range groups {
+			add("@"+name, "alias group")
+		}
+	}
+
+	roles, _ := cli.GetRolesFromCache()
+
+	for _, role := range roles {
+		add(role.RoleArn, accountDescription(role.RoleArn))
+	}
+
+	return completions
+}
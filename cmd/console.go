@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/redbubble/yak/aws"
+	"github.com/redbubble/yak/cli"
+)
+
+func consoleCmd(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Please specify exactly one role to open the console as.")
+	}
+
+	role, err := cli.ResolveRole(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	creds, err := cli.AssumeRole(role)
+	if err != nil {
+		return err
+	}
+
+	signinUrl, err := aws.ConsoleSigninUrl(creds, aws.ConsoleOptions{
+		FederationEndpoint: viper.GetString("console.federation_endpoint"),
+		Service:            viper.GetString("console.service"),
+		Region:             consoleRegion(role),
+		SessionDuration:    viper.GetInt64("console.session_duration"),
+		Issuer:             "yak",
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if viper.GetBool("console.open") {
+		return openUrl(signinUrl)
+	}
+
+	fmt.Println(signinUrl)
+
+	return nil
+}
+
+func consoleRegion(role cli.Role) string {
+	if region := viper.GetString("console.region"); region != "" {
+		return region
+	}
+
+	return role.Region
+}
+
+func openUrl(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Run()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Run()
+	default:
+		return exec.Command("xdg-open", url).Run()
+	}
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/redbubble/yak/awsconfig"
+	"github.com/redbubble/yak/cli"
+)
+
+func writeCredentialsCmd(cmd *cobra.Command, args []string) error {
+	profile := viper.GetString("write-credentials")
+
+	if len(args) != 1 {
+		return errors.New("Please specify exactly one role to write credentials for.")
+	}
+
+	role, err := cli.ResolveRole(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	// Assume the role before taking the lock, since that might mean waiting on
+	// the user to log in
+	stsOutput, err := cli.AssumeRole(role)
+
+	if err != nil {
+		return err
+	}
+
+	creds := awsconfig.Credentials{
+		Role:            args[0],
+		AccessKeyId:     *stsOutput.Credentials.AccessKeyId,
+		SecretAccessKey: *stsOutput.Credentials.SecretAccessKey,
+		SessionToken:    *stsOutput.Credentials.SessionToken,
+		Expiration:      *stsOutput.Credentials.Expiration,
+		WriteExpiration: viper.GetBool("credentials_file.write_expiration"),
+	}
+
+	return updateCredentialsFile(func(credentialsPath string, content string) (string, error) {
+		if viper.GetBool("remove-expired-credentials") {
+			content = removeExpiredCredentials(credentialsPath, content)
+		}
+
+		log.Infof("Writing credentials for %s to profile %s in %s", args[0], profile, credentialsPath)
+
+		return awsconfig.SetCredentials(content, profile, creds)
+	})
+}
+
+func removeExpiredCredentialsCmd() error {
+	return updateCredentialsFile(func(credentialsPath string, content string) (string, error) {
+		return removeExpiredCredentials(credentialsPath, content), nil
+	})
+}
+
+func removeExpiredCredentials(credentialsPath string, content string) string {
+	updated, removed := awsconfig.RemoveExpiredCredentials(content, time.Now())
+
+	if len(removed) > 0 {
+		log.Infof("Removing expired credentials for %s from %s", strings.Join(removed, ", "), credentialsPath)
+	}
+
+	return updated
+}
+
+func updateCredentialsFile(update func(string, string) (string, error)) error {
+	credentialsPath, err := awsconfig.CredentialsPath()
+
+	if err != nil {
+		return err
+	}
+
+	unlock, err := awsconfig.LockFile(credentialsPath)
+
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	content, err := awsconfig.ReadFile(credentialsPath)
+
+	if err != nil {
+		return err
+	}
+
+	updated, err := update(credentialsPath, content)
+
+	if err != nil || updated == content {
+		return err
+	}
+
+	return awsconfig.WriteFileAtomic(credentialsPath, updated)
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/redbubble/yak/aws"
+	"github.com/redbubble/yak/cli"
+)
+
+type eachResult struct {
+	role string
+	err  error
+}
+
+func eachCmd(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 {
+		return errors.New("Please specify a command to run as each role.")
+	}
+
+	roleNames, err := cli.SelectRoles(viper.GetString("each"))
+
+	if err != nil {
+		return err
+	}
+
+	// Assuming the roles one at a time means only one login, with any
+	// prompting it needs, before the commands start
+	environments := map[string][]string{}
+	results := []eachResult{}
+
+	for _, name := range roleNames {
+		env, err := eachEnvironment(name)
+
+		if err != nil {
+			log.Warnf("Could not get credentials for %s: %v", name, err)
+			results = append(results, eachResult{role: name, err: err})
+			continue
+		}
+
+		environments[name] = env
+	}
+
+	parallelism := viper.GetInt("each.parallelism")
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	signal.Stop(terminationSignals)
+
+	var running sync.Map
+	signals := make(chan os.Signal, 4)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	go func() {
+		for sig := range signals {
+			// The commands share yak's terminal, so they'll have had Ctrl-C already
+			if sig == os.Interrupt {
+				continue
+			}
+
+			running.Range(func(_, process interface{}) bool {
+				process.(*os.Process).Signal(sig)
+				return true
+			})
+		}
+	}()
+
+	outputMutex := sync.Mutex{}
+	resultsMutex := sync.Mutex{}
+	slots := make(chan struct{}, parallelism)
+	wait := sync.WaitGroup{}
+
+	for _, name := range roleNames {
+		env, ok := environments[name]
+
+		if !ok {
+			continue
+		}
+
+		wait.Add(1)
+		slots <- struct{}{}
+
+		go func(name string, env []string) {
+			defer wait.Done()
+			defer func() { <-slots }()
+
+			err := runAs(name, args, env, &outputMutex, &running)
+
+			resultsMutex.Lock()
+			results = append(results, eachResult{role: name, err: err})
+			resultsMutex.Unlock()
+		}(name, env)
+	}
+
+	wait.Wait()
+
+	return summariseEach(roleNames, results)
+}
+
+func eachEnvironment(name string) ([]string, error) {
+	role, err := cli.ResolveRole(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := cli.AssumeRole(role)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cli.EnrichedEnvironment(
+		aws.EnvironmentVariables(creds),
+		cli.RegionEnvironmentVariables(role),
+		cli.RoleEnvironmentVariables(role),
+	), nil
+}
+
+func runAs(name string, command []string, env []string, outputMutex *sync.Mutex, running *sync.Map) error {
+	stdout := cli.NewPrefixWriter(fmt.Sprintf("[%s] ", name), os.Stdout, outputMutex)
+	stderr := cli.NewPrefixWriter(fmt.Sprintf("[%s] ", name), os.Stderr, outputMutex)
+
+	defer stdout.Flush()
+	defer stderr.Flush()
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	running.Store(name, cmd.Process)
+	defer running.Delete(name)
+
+	return cmd.Wait()
+}
+
+func summariseEach(roleNames []string, results []eachResult) error {
+	errs := map[string]error{}
+	for _, result := range results {
+		errs[result.role] = result.err
+	}
+
+	failed := 0
+
+	fmt.Fprintln(os.Stderr)
+	for _, name := range roleNames {
+		err, ok := errs[name]
+
+		switch {
+		case !ok:
+			fmt.Fprintf(os.Stderr, "%s: not run\n", name)
+			failed++
+		case err == nil:
+			fmt.Fprintf(os.Stderr, "%s: ok\n", name)
+		default:
+			if exitError, isExitError := err.(*exec.ExitError); isExitError {
+				fmt.Fprintf(os.Stderr, "%s: exited with status %d\n", name, getExitCode(exitError))
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: failed: %v\n", name, err)
+			}
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("The command failed for %d of %d roles", failed, len(roleNames))
+	}
+
+	return nil
+}
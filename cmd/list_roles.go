@@ -2,64 +2,127 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
-	"github.com/redbubble/yak/cache"
 	"github.com/redbubble/yak/cli"
+	"github.com/redbubble/yak/format"
+	"github.com/redbubble/yak/saml"
 )
 
 func listRolesCmd(cmd *cobra.Command, args []string) error {
-	roles, gotRoles := cli.GetRolesFromCache()
+	roles, err := cli.GetRoles()
 
-	if !gotRoles {
-		log.Infof("Role list not in cache, grabbing from AWS")
-		loginData, err := cli.GetLoginDataWithTimeout()
+	if err != nil {
+		return err
+	}
+
+	aliases, err := cli.GetAliases()
+
+	if err != nil {
+		return err
+	}
+
+	// Given a pattern, only list the roles it selects
+	selected := func(name string) bool { return true }
+
+	if len(args) > 0 {
+		names, err := cli.SelectRoles(args[0])
 
 		if err != nil {
 			return err
 		}
 
-		cli.CacheLoginRoles(loginData.Roles)
-		cache.Export()
+		selectedNames := map[string]bool{}
+		for _, name := range names {
+			selectedNames[name] = true
+		}
+
+		selected = func(name string) bool { return selectedNames[name] }
+	}
 
-		roles = (loginData.Roles)
+	selectedRoles := []saml.LoginRole{}
+	for _, role := range roles {
+		if selected(role.RoleArn) {
+			selectedRoles = append(selectedRoles, role)
+		}
 	}
 
-	aliases, _ := getAliases()
+	descriptions := cli.DescribeRoles(selectedRoles, aliases, cli.AccountNames(selectedRoles))
 
-	for _, alias := range aliases {
-		fmt.Printf("    %s\n", alias)
+	if listFormat := viper.GetString("list_roles.output"); listFormat != "text" {
+		return printRoleRecords(listFormat, descriptions)
 	}
 
-	for _, role := range roles {
-		fmt.Printf("    %s\n", role.RoleArn)
+	aliasNames := []string{}
+	for name, _ := range aliases {
+		if selected(name) {
+			aliasNames = append(aliasNames, name)
+		}
+	}
+	sort.Strings(aliasNames)
+
+	for _, name := range aliasNames {
+		alias := aliases[name]
+
+		line := name
+		if alias.Chained() {
+			line = fmt.Sprintf("%s -> %s (via %s)", name, alias.Arn, alias.Source)
+		}
+
+		if alias.Description != "" {
+			line += " - " + alias.Description
+		}
+
+		fmt.Printf("    %s\n", line)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	account := ""
+
+	for _, description := range descriptions {
+		if description.AccountLabel() != account {
+			account = description.AccountLabel()
+			fmt.Fprintf(writer, "\n%s\n", account)
+		}
+
+		fmt.Fprintf(writer, "    %s\t%s\t%s\n", description.RoleName, strings.Join(description.Aliases, ", "), description.PrincipalArn)
 	}
+
+	writer.Flush()
 	fmt.Println()
 
 	return nil
 }
 
-func getAliases() ([]string, error) {
-	var aliases map[string]string
-
-	if !viper.IsSet("alias") {
-		return []string{}, nil
+func printRoleRecords(listFormat string, descriptions []cli.RoleDescription) error {
+	records := []format.RoleRecord{}
+
+	for _, description := range descriptions {
+		records = append(records, format.RoleRecord{
+			RoleArn:           description.RoleArn,
+			PrincipalArn:      description.PrincipalArn,
+			AccountId:         description.AccountId,
+			AccountName:       description.AccountName,
+			RoleName:          description.RoleName,
+			Aliases:           description.Aliases,
+			CredentialsCached: description.CachedUntil != nil,
+			Expiration:        description.CachedUntil,
+		})
 	}
 
-	err := viper.Sub("alias").Unmarshal(&aliases)
+	output, err := format.Roles(listFormat, records)
 
 	if err != nil {
-		return []string{}, err
+		return err
 	}
 
-	keys := []string{}
-
-	for key, _ := range aliases {
-		keys = append(keys, key)
-	}
+	fmt.Print(output)
 
-	return keys, nil
+	return nil
 }
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/redbubble/yak/cli"
+)
+
+// wantsPicker tells whether to ask which role to use: when asked to, or when
+// a mode that needs a role wasn't given one and there's someone to ask
+func wantsPicker(args []string) bool {
+	if viper.GetBool("pick") {
+		return true
+	}
+
+	if len(args) > 0 || !cli.PickerAvailable() {
+		return false
+	}
+
+	return !viper.GetBool("list-roles") &&
+		!viper.GetBool("write-aws-config") &&
+		!viper.GetBool("credential-process") &&
+		viper.GetString("each") == ""
+}
+
+// pickRole returns no role, rather than an error, when the picker only came up
+// because no role was given and there's nothing in it to pick
+func pickRole() (string, error) {
+	aliases, err := cli.GetAliases()
+
+	if err != nil {
+		return "", err
+	}
+
+	// Picking should be quick, so rather than logging in to fetch the roles,
+	// make do with the ones we know about
+	roles, gotRoles := cli.GetRolesFromCache()
+
+	if !gotRoles && len(aliases) > 0 {
+		log.Warn("No roles are cached, so only your aliases are listed. Run 'yak --list-roles' to fetch your roles.")
+	}
+
+	items := []cli.PickerItem{}
+
+	aliasNames := []string{}
+	for name := range aliases {
+		aliasNames = append(aliasNames, name)
+	}
+	sort.Strings(aliasNames)
+
+	for _, name := range aliasNames {
+		alias := aliases[name]
+		description := alias.Description
+
+		if description == "" {
+			description = alias.Arn
+		}
+
+		items = append(items, cli.PickerItem{Name: name, Description: description})
+	}
+
+	for _, role := range roles {
+		items = append(items, cli.PickerItem{Name: role.RoleArn, Description: accountDescription(role.RoleArn)})
+	}
+
+	if len(items) == 0 && !viper.GetBool("pick") {
+		return "", nil
+	}
+
+	return cli.PickRole(items)
+}
+
+// givenRole tells whether a role came before any '--', which is where yak
+// expects it; anything after the '--' is the command
+func givenRole(cmd *cobra.Command, args []string) bool {
+	dash := cmd.ArgsLenAtDash()
+
+	if dash < 0 {
+		return len(args) > 0
+	}
+
+	return dash > 0
+}
+
+func accountDescription(roleArn string) string {
+	parsed, err := arn.Parse(roleArn)
+
+	if err != nil {
+		return ""
+	}
+
+	return cli.CachedAccountName(parsed.AccountID)
+}
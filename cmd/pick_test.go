@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestGivenRole(t *testing.T) {
+	scenarios := []struct {
+		args     string
+		expected bool
+	}{
+		{"", false},
+		{"prod", true},
+		{"-- aws s3 ls", false},
+		{"prod -- aws s3 ls", true},
+	}
+
+	for _, scenario := range scenarios {
+		var got bool
+		cmd := &cobra.Command{
+			Run: func(cmd *cobra.Command, args []string) {
+				got = givenRole(cmd, args)
+			},
+		}
+		cmd.SetArgs(strings.Fields(scenario.args))
+		cmd.Execute()
+
+		if got != scenario.expected {
+			t.Log("---------------")
+			t.Logf("Did not tell whether 'yak %s' was given a role", scenario.args)
+			t.Logf("Expected: %t", scenario.expected)
+			t.Logf("Got: %t", got)
+			t.Fail()
+		}
+	}
+}
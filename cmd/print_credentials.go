@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/spf13/cobra"
@@ -11,18 +12,23 @@ import (
 )
 
 func printCredentialsCmd(cmd *cobra.Command, args []string) error {
-	roleName, err := cli.ResolveRole(args[0])
+	role, err := cli.ResolveRole(args[0])
 
 	if err != nil {
 		return err
 	}
 
-	creds, err := cli.AssumeRole(roleName)
+	creds, err := cli.AssumeRole(role)
 	if err != nil {
 		return err
 	}
 
-	output, err := format.Credentials(viper.GetString("output.format"), creds)
+	extraEnv := cli.RegionEnvironmentVariables(role)
+	for key, value := range role.Env {
+		extraEnv[key] = value
+	}
+
+	output, err := format.Credentials(viper.GetString("output.format"), creds, extraEnv)
 
 	if err != nil {
 		return err
@@ -32,3 +38,14 @@ func printCredentialsCmd(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func credentialProcessCmd(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Please specify exactly one role for --credential-process.")
+	}
+
+	// The AWS CLI and SDKs read our stdout as JSON, so it mustn't contain anything else
+	viper.Set("output.format", "credential_process")
+
+	return printCredentialsCmd(cmd, args)
+}
@@ -16,17 +16,49 @@ import (
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh/terminal"
 
+	"github.com/redbubble/yak/cli"
 	"github.com/redbubble/yak/format"
 )
 
+// Until a command is running, a termination signal means yak should tidy up
+// the terminal and exit; after that, signals are passed on to the command
+var terminationSignals = make(chan os.Signal, 2)
+
 var rootCmd = &cobra.Command{
-	Use:   "yak [flags] [--list-roles | [--] <role> [<command...>]]",
+	Use:   "yak [flags] [--list-roles | --console <role> | [--] <role> [<command...>]]",
 	Short: "A shim to do stuff with AWS credentials using Okta",
 	Long: `A shim to do stuff with AWS credentials using Okta
 
   * With --list-roles, print a list of your available AWS roles.
     Otherwise, yak will attempt to generate AWS keys for <role>.
 
+  * With --console, print a sign-in URL for the AWS console as <role>.
+
+  * With --write-credentials <profile>, write the credentials for <role>
+    into that profile in ~/.aws/credentials instead.
+
+  * With --write-aws-config, write a profile for each of your roles
+    into ~/.aws/config, which gets its credentials from yak.
+
+  * With --each <pattern>, run <command> as every role or alias
+    matching <pattern>, with each line of output labelled.
+
+    Anywhere a role is expected, you can use a glob like 'prod-*', a
+    regular expression like '/^prod-(admin|ro)$/', or '@group' for the
+    members of an alias group; for a single role, it must match
+    exactly one.
+
+  * With --shell, start an interactive shell as <role>.
+
+  * With --pick, or if no <role> is given in a terminal, choose one
+    from a list of your aliases and roles, typing to narrow it down.
+
+  * 'yak completion <shell>' prints a completion script for bash, zsh,
+    fish or powershell, which suggests your aliases and cached roles.
+
+  * 'yak serve <role>' serves credentials for <role> to containers
+    and long-running tools, refreshing them before they expire.
+
   * If <command> is set, yak will attempt to execute it with the
     AWS keys injected into the environment.  Otherwise, the
     credentials will conveniently be printed stdout.
@@ -36,16 +68,12 @@ var rootCmd = &cobra.Command{
     knows not to interpret those arguments for itself`,
 	SilenceUsage:  true,
 	SilenceErrors: true,
-	RunE: func(cmd *cobra.Command, args []string) error {
+	Args:          cobra.ArbitraryArgs,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		var err error
 
-		if viper.GetBool("version") {
-			versionCmd()
-			return nil
-		}
-
-		if viper.GetBool("credits") {
-			creditsCmd()
+		// These don't need any of the setup below, and shouldn't fail just because it isn't configured
+		if viper.GetBool("version") || viper.GetBool("credits") || isCompletionCommand(cmd) {
 			return nil
 		}
 
@@ -59,10 +87,10 @@ var rootCmd = &cobra.Command{
 			return errors.New("Please don't use --no-cache and --clear-cache simultaneously.")
 		}
 
-		// If we've made it to this point, we need to have an Okta domain and an AWS path
-		if viper.GetString("okta.domain") == "" || viper.GetString("okta.aws_saml_endpoint") == "" {
-			return errors.New(`An Okta domain and an AWS SAML Endpoint must be configured for yak to work.
-These can be configured either in the [okta] section of ~/.config/yak/config.toml or by passing the --okta-domain and --okta-aws-saml-endpoint arguments.`)
+		// If we've made it to this point, we need a properly configured identity provider
+		_, err = cli.GetIdentityProvider()
+		if err != nil {
+			return err
 		}
 
 		// If the output format is invalid, exit here to provide consistent UX across all commands
@@ -71,6 +99,15 @@ These can be configured either in the [okta] section of ~/.config/yak/config.tom
 			return err
 		}
 
+		err = format.ValidateRoleListFormat(viper.GetString("list_roles.output"))
+		if err != nil {
+			return err
+		}
+
+		if viper.GetBool("list-roles") && cmd.Flags().Changed("output-format") {
+			return errors.New("--output-format only applies to credentials. To list roles as JSON or CSV, use --output json or --output csv")
+		}
+
 		if viper.GetBool("debug") {
 			log.SetLevel(log.DebugLevel)
 		} else if viper.GetBool("verbose") {
@@ -79,19 +116,12 @@ These can be configured either in the [okta] section of ~/.config/yak/config.tom
 			log.SetLevel(log.WarnLevel)
 		}
 
-		if viper.GetBool("clear-cache") {
-			clearCache()
-
-			if !viper.GetBool("list-roles") && len(args) == 0 {
-				return nil
-			}
-		}
+		warnAboutShadowedAliases(cmd.Root())
 
 		state, stateErr := terminal.GetState(int(syscall.Stdin))
-		channel := make(chan os.Signal, 2)
-		signal.Notify(channel, os.Interrupt, syscall.SIGTERM)
+		signal.Notify(terminationSignals, os.Interrupt, syscall.SIGTERM)
 		go func() {
-			<-channel
+			<-terminationSignals
 			fmt.Fprintln(os.Stderr, "Received termination signal, exiting...")
 			if stateErr == nil {
 				terminal.Restore(int(syscall.Stdin), state)
@@ -100,8 +130,68 @@ These can be configured either in the [okta] section of ~/.config/yak/config.tom
 			os.Exit(1)
 		}()
 
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+
+		if viper.GetBool("version") {
+			versionCmd()
+			return nil
+		}
+
+		if viper.GetBool("credits") {
+			creditsCmd()
+			return nil
+		}
+
+		if viper.GetBool("clear-cache") {
+			clearCache()
+
+			if !moreToDo(args) {
+				return nil
+			}
+		}
+
+		if viper.GetBool("remove-expired-credentials") && viper.GetString("write-credentials") == "" {
+			err = removeExpiredCredentialsCmd()
+
+			if err != nil || !moreToDo(args) {
+				return err
+			}
+		}
+
+		if viper.GetBool("pick") && givenRole(cmd, args) {
+			return errors.New("--pick chooses the role for you, so it can't be given one as well. To run a command as the chosen role, put it after '--', e.g. 'yak --pick -- <command>'")
+		}
+
+		if wantsPicker(args) {
+			role, err := pickRole()
+
+			if err != nil {
+				return err
+			}
+
+			// With nothing to pick from, plain 'yak' shows the help as it always has
+			if role != "" {
+				args = append([]string{role}, args...)
+			}
+		}
+
 		if viper.GetBool("list-roles") {
 			err = listRolesCmd(cmd, args)
+		} else if viper.GetString("write-credentials") != "" {
+			err = writeCredentialsCmd(cmd, args)
+		} else if viper.GetBool("write-aws-config") {
+			err = writeAwsConfigCmd(cmd, args)
+		} else if viper.GetBool("credential-process") {
+			err = credentialProcessCmd(cmd, args)
+		} else if viper.GetBool("console") {
+			err = consoleCmd(cmd, args)
+		} else if viper.GetString("each") != "" {
+			err = eachCmd(cmd, args)
+		} else if viper.GetBool("shell") {
+			err = shellCmd(cmd, args)
 		} else if len(args) == 1 {
 			err = printCredentialsCmd(cmd, args)
 		} else if len(args) > 1 {
@@ -121,13 +211,43 @@ func init() {
 
 	rootCmd.PersistentFlags().BoolP("help", "h", false, "Display this help message and exit")
 	rootCmd.PersistentFlags().BoolP("list-roles", "l", false, "List available AWS roles and exit")
+	rootCmd.PersistentFlags().String("output", "", "With --list-roles, print one record per role as 'json' or 'csv' instead of the usual text. For credentials, use --output-format")
+	rootCmd.PersistentFlags().Bool("console", false, "Print a sign-in URL for the AWS console as <role> and exit")
+	rootCmd.PersistentFlags().String("each", "", "Run <command> as each role or alias matching this pattern, e.g. 'prod-*' or '@group'")
+	rootCmd.PersistentFlags().Int("each-parallelism", 4, "With --each, the number of roles to run <command> as at once")
+	rootCmd.PersistentFlags().Bool("shell", false, "Start an interactive shell as <role>")
+	rootCmd.PersistentFlags().Bool("pick", false, "Choose <role> from a list of your aliases and roles; this is the default when no role is given in a terminal")
+	rootCmd.PersistentFlags().Bool("credential-process", false, "Print credentials for <role> in the format expected by credential_process in ~/.aws/config")
+	rootCmd.PersistentFlags().Bool("write-aws-config", false, "Write a profile for each role and alias into ~/.aws/config and exit")
+	rootCmd.PersistentFlags().Bool("refresh", false, "When running <command>, serve it credentials that are refreshed before they expire, instead of setting them in its environment")
+	rootCmd.PersistentFlags().Bool("dry-run", false, "With --write-aws-config, print the changes that would be made instead of making them")
+	rootCmd.PersistentFlags().String("write-credentials", "", "Write credentials for <role> into this profile in ~/.aws/credentials, instead of printing them")
+	rootCmd.PersistentFlags().Bool("credentials-expiration", false, "With --write-credentials, also write the expiration time of the credentials")
+	rootCmd.PersistentFlags().Bool("remove-expired-credentials", false, "Remove expired credentials written by yak from ~/.aws/credentials. If no role is given, exit without error")
 	rootCmd.PersistentFlags().Bool("clear-cache", false, "Delete all data from yak's cache. If no other arguments are given, exit without error")
 	rootCmd.PersistentFlags().Bool("version", false, "Print the current version and exit")
 	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Print our actions as we take them")
 	rootCmd.PersistentFlags().Bool("debug", false, "Print detailed debug information, including session tokens")
 
 	rootCmd.PersistentFlags().Bool("credits", false, "Print the contributing authors")
+
+	rootCmd.ValidArgsFunction = completeRootArgs
+	rootCmd.RegisterFlagCompletionFunc("each", completeEach)
+
 	viper.BindPFlag("list-roles", rootCmd.PersistentFlags().Lookup("list-roles"))
+	viper.BindPFlag("list_roles.output", rootCmd.PersistentFlags().Lookup("output"))
+	viper.BindPFlag("console", rootCmd.PersistentFlags().Lookup("console"))
+	viper.BindPFlag("credential-process", rootCmd.PersistentFlags().Lookup("credential-process"))
+	viper.BindPFlag("write-aws-config", rootCmd.PersistentFlags().Lookup("write-aws-config"))
+	viper.BindPFlag("each", rootCmd.PersistentFlags().Lookup("each"))
+	viper.BindPFlag("each.parallelism", rootCmd.PersistentFlags().Lookup("each-parallelism"))
+	viper.BindPFlag("pick", rootCmd.PersistentFlags().Lookup("pick"))
+	viper.BindPFlag("shell", rootCmd.PersistentFlags().Lookup("shell"))
+	viper.BindPFlag("refresh", rootCmd.PersistentFlags().Lookup("refresh"))
+	viper.BindPFlag("dry-run", rootCmd.PersistentFlags().Lookup("dry-run"))
+	viper.BindPFlag("write-credentials", rootCmd.PersistentFlags().Lookup("write-credentials"))
+	viper.BindPFlag("credentials_file.write_expiration", rootCmd.PersistentFlags().Lookup("credentials-expiration"))
+	viper.BindPFlag("remove-expired-credentials", rootCmd.PersistentFlags().Lookup("remove-expired-credentials"))
 	viper.BindPFlag("clear-cache", rootCmd.PersistentFlags().Lookup("clear-cache"))
 	viper.BindPFlag("version", rootCmd.PersistentFlags().Lookup("version"))
 	viper.BindPFlag("credits", rootCmd.PersistentFlags().Lookup("credits"))
@@ -139,8 +259,18 @@ func init() {
 	rootCmd.PersistentFlags().String("okta-aws-saml-endpoint", "", "The app embed path for the AWS app within Okta")
 	rootCmd.PersistentFlags().String("okta-mfa-type", "", "The Okta MFA type for login")
 	rootCmd.PersistentFlags().String("okta-mfa-provider", "", "The Okta MFA provider name for login")
-	rootCmd.PersistentFlags().StringP("output-format", "o", "", "Can be set to either 'json' or 'env'. The format in which to output credential data")
+	rootCmd.PersistentFlags().String("login-provider", "", "The identity provider to log in with. Can be set to either 'okta' or 'form'")
+	rootCmd.PersistentFlags().String("form-login-url", "", "The URL of the login page for the form identity provider")
+	rootCmd.PersistentFlags().String("form-username", "", "Your username for the form identity provider")
+	rootCmd.PersistentFlags().String("saml-assertion-file", "", "Read a SAML assertion (XML or base64) from this file, or stdin if '-', instead of logging in")
+	rootCmd.PersistentFlags().StringP("output-format", "o", "", "Can be set to 'json', 'env' or 'credential_process'. The format in which to output credential data. For --list-roles, use --output")
 	rootCmd.PersistentFlags().Int64P("aws-session-duration", "d", 0, "The session duration to request from AWS (in seconds)")
+	rootCmd.PersistentFlags().String("aws-sts-region", "", "The region to use for STS requests; also exported as AWS_REGION and AWS_DEFAULT_REGION")
+	rootCmd.PersistentFlags().String("aws-sts-endpoint", "", "A custom STS endpoint URL, e.g. for a VPC endpoint")
+	rootCmd.PersistentFlags().String("console-service", "", "The service to open in the AWS console, e.g. 'ec2'")
+	rootCmd.PersistentFlags().String("console-region", "", "The region to open in the AWS console")
+	rootCmd.PersistentFlags().Int64("console-session-duration", 0, "The duration of the AWS console session (in seconds)")
+	rootCmd.PersistentFlags().Bool("console-open", false, "Open the AWS console sign-in URL in your browser instead of printing it")
 	rootCmd.PersistentFlags().Bool("no-cache", false, "Ignore cache for this request. Mutually exclusive with --cache-only")
 	rootCmd.PersistentFlags().Bool("cache-only", false, "Only use cache, do not make external requests. Mutually exclusive with --no-cache")
 	rootCmd.PersistentFlags().Bool("pinentry", false, "Use the pinentry to prompt for credentials, instead of terminal (useful for GUI applications)")
@@ -149,7 +279,17 @@ func init() {
 	viper.BindPFlag("okta.aws_saml_endpoint", rootCmd.PersistentFlags().Lookup("okta-aws-saml-endpoint"))
 	viper.BindPFlag("okta.mfa_type", rootCmd.PersistentFlags().Lookup("okta-mfa-type"))
 	viper.BindPFlag("okta.mfa_provider", rootCmd.PersistentFlags().Lookup("okta-mfa-provider"))
+	viper.BindPFlag("login.provider", rootCmd.PersistentFlags().Lookup("login-provider"))
+	viper.BindPFlag("form.login_url", rootCmd.PersistentFlags().Lookup("form-login-url"))
+	viper.BindPFlag("form.username", rootCmd.PersistentFlags().Lookup("form-username"))
+	viper.BindPFlag("saml_assertion_file", rootCmd.PersistentFlags().Lookup("saml-assertion-file"))
 	viper.BindPFlag("aws.session_duration", rootCmd.PersistentFlags().Lookup("aws-session-duration"))
+	viper.BindPFlag("aws.sts_region", rootCmd.PersistentFlags().Lookup("aws-sts-region"))
+	viper.BindPFlag("aws.sts_endpoint", rootCmd.PersistentFlags().Lookup("aws-sts-endpoint"))
+	viper.BindPFlag("console.service", rootCmd.PersistentFlags().Lookup("console-service"))
+	viper.BindPFlag("console.region", rootCmd.PersistentFlags().Lookup("console-region"))
+	viper.BindPFlag("console.session_duration", rootCmd.PersistentFlags().Lookup("console-session-duration"))
+	viper.BindPFlag("console.open", rootCmd.PersistentFlags().Lookup("console-open"))
 	viper.BindPFlag("cache.no_cache", rootCmd.PersistentFlags().Lookup("no-cache"))
 	viper.BindPFlag("cache.cache_only", rootCmd.PersistentFlags().Lookup("cache-only"))
 	viper.BindPFlag("output.format", rootCmd.PersistentFlags().Lookup("output-format"))
@@ -185,6 +325,15 @@ func clearCache() {
 	os.Remove(viper.GetString("cache.file_location"))
 }
 
+// moreToDo tells whether housekeeping flags like --clear-cache came with
+// something else to do, or are all yak was asked for
+func moreToDo(args []string) bool {
+	return len(args) > 0 ||
+		viper.GetBool("list-roles") ||
+		viper.GetBool("write-aws-config") ||
+		viper.GetBool("pick")
+}
+
 func initConfig() {
 	viper.AddConfigPath(getConfigPath())
 	viper.AddConfigPath(oldConfigPath())
@@ -254,7 +403,16 @@ func defaultConfigValues() {
 	viper.SetDefault("okta.session_cache_limit", 86400)
 	viper.SetDefault("aws.session_duration", 3600)
 	viper.SetDefault("output.format", "env")
+	viper.SetDefault("list_roles.output", "text")
 	viper.SetDefault("login.timeout", 180)
+	viper.SetDefault("login.provider", "okta")
+	viper.SetDefault("server.refresh_window", 600)
+	viper.SetDefault("exec.strip_variables", []string{"AWS_PROFILE", "AWS_DEFAULT_PROFILE", "AWS_SECURITY_TOKEN", "AWS_SESSION_EXPIRATION", "AWS_CREDENTIAL_EXPIRATION"})
+	viper.SetDefault("aws_config.profile_name", "{account_alias}-{role_name}")
+	viper.SetDefault("aws_config.alias_profile_name", "{alias}")
+	viper.SetDefault("aws_config.yak_command", "yak")
+	viper.SetDefault("form.username_field", "username")
+	viper.SetDefault("form.password_field", "password")
 }
 
 func Execute() {
@@ -276,7 +434,25 @@ func Execute() {
 	}
 }
 
+// warnAboutShadowedAliases points out aliases that can't be used as the first
+// argument, because yak runs the subcommand of the same name instead
+func warnAboutShadowedAliases(root *cobra.Command) {
+	for _, subcommand := range root.Commands() {
+		for _, name := range append([]string{subcommand.Name()}, subcommand.Aliases...) {
+			if viper.IsSet("alias." + name) {
+				log.Warnf("The alias '%s' has the same name as the 'yak %s' command, so 'yak %s' runs the command; please rename the alias", name, name, name)
+			}
+		}
+	}
+}
+
 func getExitCode(err *exec.ExitError) int {
 	ws := err.Sys().(syscall.WaitStatus)
+
+	// Follow the shell convention for commands killed by a signal
+	if ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+
 	return ws.ExitStatus()
 }
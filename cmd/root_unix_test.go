@@ -0,0 +1,41 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestGetExitCode(t *testing.T) {
+	scenarios := []struct {
+		script   string
+		expected int
+	}{
+		{script: "exit 3", expected: 3},
+		// Follows the shell convention of 128 + the signal number
+		{script: "kill -TERM $$", expected: 143},
+		{script: "kill -INT $$", expected: 130},
+	}
+
+	for _, scenario := range scenarios {
+		err := exec.Command("sh", "-c", scenario.script).Run()
+		exitError, ok := err.(*exec.ExitError)
+
+		if !ok {
+			t.Log("---------------")
+			t.Logf("Running '%s' did not give an exit error", scenario.script)
+			t.Logf("Got: %v", err)
+			t.Fail()
+			continue
+		}
+
+		if code := getExitCode(exitError); code != scenario.expected {
+			t.Log("---------------")
+			t.Logf("Wrong exit code for '%s'", scenario.script)
+			t.Logf("Expected: %d", scenario.expected)
+			t.Logf("Got: %d", code)
+			t.Fail()
+		}
+	}
+}
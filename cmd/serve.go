@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+
+	"github.com/redbubble/yak/cli"
+	"github.com/redbubble/yak/credserver"
+	"github.com/redbubble/yak/format"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [flags] <role>",
+	Short: "Serve credentials for <role> to containers and long-running tools",
+	Long: `Serve credentials for <role> to containers and long-running tools
+
+  Runs a server on a loopback address which speaks the AWS container
+  credentials protocol, refreshing the credentials before they expire.
+  Point the AWS SDKs at it by setting the environment variables yak
+  prints when it starts.
+
+  With --imds, emulate the EC2 instance metadata service (IMDSv2)
+  instead, for tools that only know how to get credentials from an
+  instance profile. The role it serves can be switched while it's
+  running by PUTing a new role to the /yak/role control endpoint.`,
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          serveRunE,
+}
+
+func init() {
+	serveCmd.Flags().String("address", "127.0.0.1:0", "The loopback address to serve credentials on. Defaults to a random port")
+	viper.BindPFlag("server.address", serveCmd.Flags().Lookup("address"))
+	serveCmd.Flags().Bool("imds", false, "Emulate the EC2 instance metadata service instead of the container credentials endpoint")
+	viper.BindPFlag("server.imds", serveCmd.Flags().Lookup("imds"))
+
+	serveCmd.ValidArgsFunction = completeServeArgs
+
+	rootCmd.AddCommand(serveCmd)
+}
+
+func serveRunE(cmd *cobra.Command, args []string) error {
+	if viper.GetBool("server.imds") {
+		return serveMetadata(args[0])
+	}
+
+	refresher, err := roleRefresher(args[0], true)
+
+	if err != nil {
+		return err
+	}
+
+	listener, err := credserver.Listen(viper.GetString("server.address"))
+
+	if err != nil {
+		return err
+	}
+
+	token, err := credserver.NewToken()
+
+	if err != nil {
+		return err
+	}
+
+	go refresher.Run(make(chan struct{}))
+
+	err = printServerEnvironment(map[string]string{
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI": fmt.Sprintf("http://%s/credentials", listener.Addr().String()),
+		"AWS_CONTAINER_AUTHORIZATION_TOKEN":  token,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Serving credentials for %s on %s; press Ctrl-C to stop\n", args[0], listener.Addr().String())
+
+	return http.Serve(listener, credserver.ContainerHandler(refresher, token))
+}
+
+func serveMetadata(roleName string) error {
+	controlToken, err := credserver.NewToken()
+
+	if err != nil {
+		return err
+	}
+
+	server, err := credserver.NewMetadataServer(roleName, roleRefresher, controlToken)
+
+	if err != nil {
+		return err
+	}
+
+	defer server.Close()
+
+	listener, err := credserver.Listen(viper.GetString("server.address"))
+
+	if err != nil {
+		return err
+	}
+
+	address := listener.Addr().String()
+
+	err = printServerEnvironment(map[string]string{
+		"AWS_EC2_METADATA_SERVICE_ENDPOINT": fmt.Sprintf("http://%s/", address),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Serving instance metadata credentials for %s on %s; press Ctrl-C to stop\n", roleName, address)
+	fmt.Fprintf(os.Stderr, "To switch roles, run: curl -X PUT -H 'Authorization: %s' -d <role> http://%s%s\n", controlToken, address, credserver.ControlRolePath)
+
+	return http.Serve(listener, server)
+}
+
+func printServerEnvironment(env map[string]string) error {
+	output, err := format.Environment(env)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(output)
+
+	return nil
+}
+
+// roleRefresher keeps credentials for a role fresh. Unless it may prompt, the
+// first set of credentials has to come without logging in, so that switching
+// roles from a request never waits on someone at a terminal.
+func roleRefresher(roleName string, prompt bool) (*credserver.Refresher, error) {
+	role, err := cli.ResolveRole(roleName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	window := time.Duration(viper.GetInt64("server.refresh_window")) * time.Second
+
+	// Otherwise every set of credentials would be due for refreshing as soon
+	// as we got it, and we'd be logging in over and over
+	if window >= role.SessionLifetime() {
+		return nil, fmt.Errorf("The refresh window (%s) must be shorter than the session duration for %s (%s). Please lower server.refresh_window.", window, roleName, role.SessionLifetime())
+	}
+
+	return credserver.NewRefresher(func(lifetime time.Duration) (*sts.AssumeRoleWithSAMLOutput, error) {
+		log.Infof("Getting credentials for %s", roleName)
+
+		if prompt {
+			return cli.AssumeRoleWithMinimumLifetime(role, lifetime)
+		}
+
+		// Only the first set needs to come without a prompt; refreshes later on
+		// can log in like they do for the role yak was started with
+		prompt = true
+		creds, err := cli.AssumeRoleWithoutPrompting(role, lifetime)
+
+		if errors.Is(err, cli.ErrPromptRequired) {
+			return nil, fmt.Errorf("%w: %v", credserver.ErrLoginRequired, err)
+		}
+
+		return creds, err
+	}, window)
+}
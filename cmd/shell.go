@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/redbubble/yak/aws"
+	"github.com/redbubble/yak/cli"
+)
+
+func shellCmd(cmd *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Please specify exactly one role to start a shell as.")
+	}
+
+	if outer := os.Getenv("YAK_ROLE"); outer != "" {
+		log.Warnf("You're already running as %s under yak; the shell for %s will be nested inside that", outer, args[0])
+	}
+
+	role, err := cli.ResolveRole(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	creds, err := cli.AssumeRole(role)
+	if err != nil {
+		return err
+	}
+
+	promptPrefix := ""
+	if viper.GetBool("shell.decorate_prompt") {
+		promptPrefix = cli.PromptPrefix(shellRoleName(role), *creds.Credentials.Expiration)
+	}
+
+	shell, err := cli.NewShell(cli.UserShell(), promptPrefix)
+
+	if err != nil {
+		return err
+	}
+
+	defer shell.Cleanup()
+
+	env := cli.EnrichedEnvironment(
+		aws.EnvironmentVariables(creds),
+		cli.RegionEnvironmentVariables(role),
+		cli.RoleEnvironmentVariables(role),
+		shell.Env,
+	)
+
+	signal.Stop(terminationSignals)
+
+	return cli.Exec(shell.Command, env)
+}
+
+// shellRoleName keeps the prompt short when a role wasn't given as an alias
+func shellRoleName(role cli.Role) string {
+	if role.Alias != "" {
+		return role.Alias
+	}
+
+	parts := strings.Split(role.Arn, "/")
+	return parts[len(parts)-1]
+}
@@ -1,14 +1,36 @@
 package cmd
 
 import (
+	"fmt"
+	"net/http"
+	"os/signal"
+
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	"github.com/redbubble/yak/aws"
 	"github.com/redbubble/yak/cli"
+	"github.com/redbubble/yak/credserver"
 )
 
+// Any of these would take precedence over a container credentials endpoint
+var refreshConflictingVariables = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_SECURITY_TOKEN",
+	"AWS_SESSION_EXPIRATION",
+	"AWS_PROFILE",
+	"AWS_DEFAULT_PROFILE",
+	"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI",
+}
+
 func shimCmd(cmd *cobra.Command, args []string) error {
-	roleName, err := cli.ResolveRole(args[0])
+	if viper.GetBool("refresh") {
+		return refreshingShimCmd(args)
+	}
+
+	role, err := cli.ResolveRole(args[0])
 
 	if err != nil {
 		return err
@@ -16,15 +38,72 @@ func shimCmd(cmd *cobra.Command, args []string) error {
 
 	command := args[1:]
 
-	creds, err := cli.AssumeRole(roleName)
+	creds, err := cli.AssumeRole(role)
 	if err != nil {
 		return err
 	}
 
-	return cli.Exec(
-		command,
-		cli.EnrichedEnvironment(
-			aws.EnvironmentVariables(creds),
-		),
+	env := cli.EnrichedEnvironment(
+		aws.EnvironmentVariables(creds),
+		cli.RegionEnvironmentVariables(role),
+		cli.RoleEnvironmentVariables(role),
 	)
+
+	// Nothing needs to happen after the command finishes, so yak can get out of the way entirely
+	if viper.GetBool("exec.replace_process") {
+		return cli.ReplaceProcess(command, env)
+	}
+
+	signal.Stop(terminationSignals)
+
+	return cli.Exec(command, env)
+}
+
+// refreshingShimCmd runs the command with a credentials endpoint of its own,
+// for commands that might outlive a single set of credentials
+func refreshingShimCmd(args []string) error {
+	role, err := cli.ResolveRole(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	refresher, err := roleRefresher(args[0], true)
+
+	if err != nil {
+		return err
+	}
+
+	listener, err := credserver.Listen("127.0.0.1:0")
+
+	if err != nil {
+		return err
+	}
+
+	defer listener.Close()
+
+	token, err := credserver.NewToken()
+
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go refresher.Run(stop)
+	go http.Serve(listener, credserver.ContainerHandler(refresher, token))
+
+	env := cli.EnrichedEnvironment(
+		map[string]string{
+			"AWS_CONTAINER_CREDENTIALS_FULL_URI": fmt.Sprintf("http://%s/credentials", listener.Addr().String()),
+			"AWS_CONTAINER_AUTHORIZATION_TOKEN":  token,
+		},
+		cli.RegionEnvironmentVariables(role),
+		cli.RoleEnvironmentVariables(role),
+	)
+
+	signal.Stop(terminationSignals)
+
+	return cli.Exec(args[1:], cli.WithoutVariables(env, refreshConflictingVariables...))
 }
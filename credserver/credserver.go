@@ -0,0 +1,177 @@
+package credserver
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	log "github.com/sirupsen/logrus"
+)
+
+// If refreshing fails, wait this long before trying again
+const retryInterval = 30 * time.Second
+
+type FetchFunc func(minimumLifetime time.Duration) (*sts.AssumeRoleWithSAMLOutput, error)
+
+// Refresher holds on to a set of credentials, and replaces them with fresh
+// ones when they're within the refresh window of expiring
+type Refresher struct {
+	fetch  FetchFunc
+	window time.Duration
+	mutex  sync.RWMutex
+	creds  *sts.AssumeRoleWithSAMLOutput
+	err    error
+}
+
+type containerCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+	Expiration      string
+	RoleArn         string
+}
+
+func NewRefresher(fetch FetchFunc, window time.Duration) (*Refresher, error) {
+	creds, err := fetch(window)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Refresher{fetch: fetch, window: window, creds: creds}, nil
+}
+
+func (refresher *Refresher) Credentials() (*sts.AssumeRoleWithSAMLOutput, error) {
+	refresher.mutex.RLock()
+	defer refresher.mutex.RUnlock()
+
+	// Refreshing can fail for a while before the credentials actually expire,
+	// so only then is it worth telling the client about it
+	if !time.Now().Before(*refresher.creds.Credentials.Expiration) {
+		if refresher.err != nil {
+			return nil, refresher.err
+		}
+
+		return nil, errors.New("The credentials expired before they could be refreshed")
+	}
+
+	return refresher.creds, nil
+}
+
+// Run refreshes the credentials in the background until stop is closed
+func (refresher *Refresher) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(refresher.untilRefresh()):
+		}
+
+		log.Infof("Refreshing credentials")
+		creds, err := refresher.fetch(refresher.window)
+
+		refresher.mutex.Lock()
+		if err != nil {
+			log.Warnf("Could not refresh credentials: %v", err)
+			refresher.err = err
+		} else {
+			refresher.creds = creds
+			refresher.err = nil
+		}
+		refresher.mutex.Unlock()
+	}
+}
+
+func (refresher *Refresher) untilRefresh() time.Duration {
+	refresher.mutex.RLock()
+	defer refresher.mutex.RUnlock()
+
+	wait := time.Until(refresher.creds.Credentials.Expiration.Add(-refresher.window))
+
+	if wait < retryInterval {
+		return retryInterval
+	}
+
+	return wait
+}
+
+func NewToken() (string, error) {
+	bytes := make([]byte, 32)
+
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(bytes), nil
+}
+
+// ContainerHandler serves credentials using the protocol the AWS SDKs use
+// for AWS_CONTAINER_CREDENTIALS_FULL_URI
+func ContainerHandler(refresher *Refresher, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validToken(r.Header.Get("Authorization"), token) {
+			log.Warnf("Rejected credentials request from %s with a bad authorization token", r.RemoteAddr)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		creds, err := refresher.Credentials()
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		WriteCredentials(w, creds)
+	})
+}
+
+func WriteCredentials(w http.ResponseWriter, creds *sts.AssumeRoleWithSAMLOutput) {
+	response := containerCredentials{
+		AccessKeyId:     *creds.Credentials.AccessKeyId,
+		SecretAccessKey: *creds.Credentials.SecretAccessKey,
+		Token:           *creds.Credentials.SessionToken,
+		Expiration:      creds.Credentials.Expiration.UTC().Format(time.RFC3339),
+	}
+
+	if creds.AssumedRoleUser != nil && creds.AssumedRoleUser.Arn != nil {
+		response.RoleArn = *creds.AssumedRoleUser.Arn
+	}
+
+	writeJson(w, response)
+}
+
+func writeJson(w http.ResponseWriter, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func validToken(given string, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
+
+// Listen opens a listener, refusing addresses other machines could reach,
+// since anything that can reach it can get credentials from it
+func Listen(address string) (net.Listener, error) {
+	host, _, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(host)
+
+	if host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("Refusing to serve credentials on %s; only loopback addresses are allowed", address)
+	}
+
+	return net.Listen("tcp", address)
+}
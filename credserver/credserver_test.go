@@ -0,0 +1,179 @@
+package credserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+func testCredentials(accessKeyId string, expiration time.Time) *sts.AssumeRoleWithSAMLOutput {
+	secretAccessKey := "alpaca"
+	sessionToken := "guanaco"
+	assumedRoleArn := "arn:aws:sts::123456789012:assumed-role/vicuña/me"
+
+	return &sts.AssumeRoleWithSAMLOutput{
+		AssumedRoleUser: &sts.AssumedRoleUser{Arn: &assumedRoleArn},
+		Credentials: &sts.Credentials{
+			AccessKeyId:     &accessKeyId,
+			SecretAccessKey: &secretAccessKey,
+			SessionToken:    &sessionToken,
+			Expiration:      &expiration,
+		},
+	}
+}
+
+func TestContainerHandler(t *testing.T) {
+	expiration := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	refresher, err := NewRefresher(func(lifetime time.Duration) (*sts.AssumeRoleWithSAMLOutput, error) {
+		return testCredentials("llama", expiration), nil
+	}, 10*time.Minute)
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("Got an error setting up the refresher")
+		t.Logf("Error: %v", err)
+		t.FailNow()
+	}
+
+	server := httptest.NewServer(ContainerHandler(refresher, "camelid"))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("Got an error requesting credentials without the token")
+		t.Logf("Error: %v", err)
+		t.FailNow()
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Log("---------------")
+		t.Log("Did not refuse a request without the token")
+		t.Logf("Expected: %d", http.StatusUnauthorized)
+		t.Logf("Got: %d", resp.StatusCode)
+		t.Fail()
+	}
+
+	request, _ := http.NewRequest("GET", server.URL, nil)
+	request.Header.Set("Authorization", "camelid")
+
+	resp, err = http.DefaultClient.Do(request)
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("Got an error requesting credentials with the token")
+		t.Logf("Error: %v", err)
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+
+	creds := containerCredentials{}
+	json.NewDecoder(resp.Body).Decode(&creds)
+
+	expected := containerCredentials{
+		AccessKeyId:     "llama",
+		SecretAccessKey: "alpaca",
+		Token:           "guanaco",
+		Expiration:      "2030-01-01T00:00:00Z",
+		RoleArn:         "arn:aws:sts::123456789012:assumed-role/vicuña/me",
+	}
+
+	if creds != expected {
+		t.Log("---------------")
+		t.Log("Did not serve the right credentials for a request with the token")
+		t.Logf("Expected: %+v", expected)
+		t.Logf("Got: %+v", creds)
+		t.Fail()
+	}
+}
+
+func TestNewRefresher(t *testing.T) {
+	_, err := NewRefresher(func(lifetime time.Duration) (*sts.AssumeRoleWithSAMLOutput, error) {
+		if lifetime != 10*time.Minute {
+			t.Log("---------------")
+			t.Log("Did not ask for credentials that last past the refresh window")
+			t.Logf("Expected: %s", 10*time.Minute)
+			t.Logf("Got: %s", lifetime)
+			t.Fail()
+		}
+
+		return testCredentials("llama", time.Now().Add(time.Hour)), nil
+	}, 10*time.Minute)
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("Got an error setting up the refresher")
+		t.Logf("Error: %v", err)
+		t.Fail()
+	}
+
+	_, err = NewRefresher(func(lifetime time.Duration) (*sts.AssumeRoleWithSAMLOutput, error) {
+		return nil, errors.New("No credentials here")
+	}, 10*time.Minute)
+
+	if err == nil {
+		t.Log("---------------")
+		t.Log("Did not fail when there were no credentials to start with")
+		t.Fail()
+	}
+}
+
+func TestExpiredCredentials(t *testing.T) {
+	refresher, err := NewRefresher(func(lifetime time.Duration) (*sts.AssumeRoleWithSAMLOutput, error) {
+		return testCredentials("llama", time.Now().Add(-time.Second)), nil
+	}, 10*time.Minute)
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("Got an error setting up the refresher")
+		t.Logf("Error: %v", err)
+		t.FailNow()
+	}
+
+	if creds, err := refresher.Credentials(); err == nil {
+		t.Log("---------------")
+		t.Log("Handed out credentials that have expired")
+		t.Logf("Got: %+v", creds)
+		t.Fail()
+	}
+
+	refreshErr := errors.New("Okta is down")
+	refresher.err = refreshErr
+
+	if _, err := refresher.Credentials(); err != refreshErr {
+		t.Log("---------------")
+		t.Log("Did not say why the credentials couldn't be refreshed")
+		t.Logf("Expected: %v", refreshErr)
+		t.Logf("Got: %v", err)
+		t.Fail()
+	}
+}
+
+func TestListen(t *testing.T) {
+	for _, address := range []string{"0.0.0.0:0", "192.0.2.1:0", "example.com:0"} {
+		if _, err := Listen(address); err == nil {
+			t.Log("---------------")
+			t.Logf("Did not refuse to listen on %s", address)
+			t.Fail()
+		}
+	}
+
+	listener, err := Listen("127.0.0.1:0")
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("Got an error listening on a loopback address")
+		t.Logf("Error: %v", err)
+		t.FailNow()
+	}
+
+	listener.Close()
+}
@@ -0,0 +1,285 @@
+package credserver
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/sts"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	imdsTokenPath       = "/latest/api/token"
+	imdsCredentialsPath = "/latest/meta-data/iam/security-credentials/"
+	imdsTokenHeader     = "X-aws-ec2-metadata-token"
+	imdsTokenTtlHeader  = "X-aws-ec2-metadata-token-ttl-seconds"
+	imdsMaxTokenTtl     = 21600
+	ControlRolePath     = "/yak/role"
+)
+
+// RefresherFunc starts refreshing credentials for a role. Unless it may
+// prompt, it should return an error wrapping ErrLoginRequired rather than
+// asking the user to log in.
+type RefresherFunc func(role string, prompt bool) (*Refresher, error)
+
+// ErrLoginRequired means switching roles would need the user to log in,
+// which can't be done from a request
+var ErrLoginRequired = errors.New("Switching to this role needs a login; run 'yak <role>' to log in, then try again")
+
+// MetadataServer emulates the parts of the EC2 instance metadata service
+// (IMDSv2) that the AWS SDKs use to get instance profile credentials. The
+// role it serves can be switched through the control endpoint.
+type MetadataServer struct {
+	newRefresher RefresherFunc
+	controlToken string
+
+	mutex     sync.RWMutex
+	role      string
+	refresher *Refresher
+	stop      chan struct{}
+	tokens    map[string]time.Time
+}
+
+type metadataCredentials struct {
+	Code            string
+	LastUpdated     string
+	Type            string
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+	Expiration      string
+}
+
+func NewMetadataServer(role string, newRefresher RefresherFunc, controlToken string) (*MetadataServer, error) {
+	refresher, err := newRefresher(role, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	server := &MetadataServer{
+		newRefresher: newRefresher,
+		controlToken: controlToken,
+		tokens:       map[string]time.Time{},
+	}
+
+	server.setRefresher(role, refresher)
+
+	return server, nil
+}
+
+func (server *MetadataServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The real IMDS refuses anything that looks like it came through a proxy
+	if r.Header.Get("X-Forwarded-For") != "" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case r.URL.Path == ControlRolePath:
+		server.serveControl(w, r)
+	case r.URL.Path == imdsTokenPath:
+		server.serveToken(w, r)
+	case strings.HasPrefix(r.URL.Path, imdsCredentialsPath):
+		server.serveCredentials(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Close stops refreshing credentials for the current role
+func (server *MetadataServer) Close() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	close(server.stop)
+}
+
+// SwitchRole starts serving credentials for another role, as long as that
+// doesn't need the user to log in
+func (server *MetadataServer) SwitchRole(role string) error {
+	// Getting credentials means talking to AWS, so don't hold the lock meanwhile
+	refresher, err := server.newRefresher(role, false)
+
+	if err != nil {
+		return err
+	}
+
+	server.setRefresher(role, refresher)
+	log.Infof("Now serving credentials for %s", role)
+
+	return nil
+}
+
+func (server *MetadataServer) setRefresher(role string, refresher *Refresher) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if server.stop != nil {
+		close(server.stop)
+	}
+
+	server.role = role
+	server.refresher = refresher
+	server.stop = make(chan struct{})
+
+	go refresher.Run(server.stop)
+}
+
+func (server *MetadataServer) serveControl(w http.ResponseWriter, r *http.Request) {
+	if !validToken(r.Header.Get("Authorization"), server.controlToken) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		server.mutex.RLock()
+		defer server.mutex.RUnlock()
+
+		fmt.Fprintln(w, server.role)
+	case http.MethodPut, http.MethodPost:
+		body, err := ioutil.ReadAll(r.Body)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		role := strings.TrimSpace(string(body))
+
+		if role == "" {
+			http.Error(w, "No role given", http.StatusBadRequest)
+			return
+		}
+
+		if err := server.SwitchRole(role); errors.Is(err, ErrLoginRequired) {
+			log.Warnf("Could not switch to role %s without logging in", role)
+			http.Error(w, ErrLoginRequired.Error(), http.StatusConflict)
+			return
+		} else if err != nil {
+			log.Warnf("Could not switch to role %s: %v", role, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		fmt.Fprintln(w, role)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (server *MetadataServer) serveToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ttl, err := strconv.Atoi(r.Header.Get(imdsTokenTtlHeader))
+
+	if err != nil || ttl < 1 || ttl > imdsMaxTokenTtl {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return
+	}
+
+	token, err := NewToken()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	server.mutex.Lock()
+	now := time.Now()
+	for existing, expiration := range server.tokens {
+		if now.After(expiration) {
+			delete(server.tokens, existing)
+		}
+	}
+	server.tokens[token] = now.Add(time.Duration(ttl) * time.Second)
+	server.mutex.Unlock()
+
+	w.Header().Set(imdsTokenTtlHeader, strconv.Itoa(ttl))
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprint(w, token)
+}
+
+func (server *MetadataServer) serveCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	server.mutex.RLock()
+	expiration, ok := server.tokens[r.Header.Get(imdsTokenHeader)]
+	refresher := server.refresher
+	server.mutex.RUnlock()
+
+	// Only IMDSv2 is emulated, so every request needs a session token
+	if !ok || time.Now().After(expiration) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	creds, err := refresher.Credentials()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	roleName, err := assumedRoleName(creds)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+
+	switch strings.TrimPrefix(r.URL.Path, imdsCredentialsPath) {
+	case "":
+		fmt.Fprint(w, roleName)
+	case roleName:
+		writeJson(w, metadataCredentials{
+			Code:            "Success",
+			LastUpdated:     time.Now().UTC().Format(time.RFC3339),
+			Type:            "AWS-HMAC",
+			AccessKeyId:     *creds.Credentials.AccessKeyId,
+			SecretAccessKey: *creds.Credentials.SecretAccessKey,
+			Token:           *creds.Credentials.SessionToken,
+			Expiration:      creds.Credentials.Expiration.UTC().Format(time.RFC3339),
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// assumedRoleName gets the role name, which is what IMDS calls the instance
+// profile, out of an arn:aws:sts::<account>:assumed-role/<name>/<session> ARN
+func assumedRoleName(creds *sts.AssumeRoleWithSAMLOutput) (string, error) {
+	if creds.AssumedRoleUser == nil || creds.AssumedRoleUser.Arn == nil {
+		return "", errors.New("No assumed role in credentials")
+	}
+
+	parsed, err := arn.Parse(*creds.AssumedRoleUser.Arn)
+
+	if err != nil {
+		return "", err
+	}
+
+	parts := strings.Split(parsed.Resource, "/")
+
+	if len(parts) < 2 || parts[0] != "assumed-role" {
+		return "", fmt.Errorf("'%s' is not an assumed role ARN", *creds.AssumedRoleUser.Arn)
+	}
+
+	return parts[1], nil
+}
@@ -0,0 +1,172 @@
+package credserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+func TestMetadataServer(t *testing.T) {
+	expiration := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	roleCredentials := map[string]*sts.AssumeRoleWithSAMLOutput{
+		"llama":  testCredentials("llama", expiration),
+		"alpaca": testCredentials("alpaca", expiration),
+	}
+	otherArn := "arn:aws:sts::123456789012:assumed-role/alpaca/me"
+	roleCredentials["alpaca"].AssumedRoleUser.Arn = &otherArn
+
+	newRefresher := func(role string, prompt bool) (*Refresher, error) {
+		if role == "vicuña" && !prompt {
+			return nil, fmt.Errorf("Cannot assume vicuña: %w", ErrLoginRequired)
+		}
+
+		creds, ok := roleCredentials[role]
+
+		if !ok {
+			return nil, errors.New("No such role")
+		}
+
+		return NewRefresher(func(time.Duration) (*sts.AssumeRoleWithSAMLOutput, error) { return creds, nil }, time.Minute)
+	}
+
+	metadataServer, err := NewMetadataServer("llama", newRefresher, "camelid")
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("Got an error starting the metadata server")
+		t.Logf("Error: %v", err)
+		t.FailNow()
+	}
+	defer metadataServer.Close()
+
+	server := httptest.NewServer(metadataServer)
+	defer server.Close()
+
+	request := func(method string, path string, headers map[string]string, body string) (int, string) {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+
+		if err != nil {
+			t.Log("---------------")
+			t.Logf("Got an error requesting %s %s", method, path)
+			t.Logf("Error: %v", err)
+			t.FailNow()
+		}
+		defer resp.Body.Close()
+
+		data, _ := ioutil.ReadAll(resp.Body)
+
+		return resp.StatusCode, string(data)
+	}
+
+	if status, _ := request("PUT", "/latest/api/token", nil, ""); status != http.StatusBadRequest {
+		t.Log("---------------")
+		t.Log("Did not refuse a session token without a TTL")
+		t.Logf("Expected: %d", http.StatusBadRequest)
+		t.Logf("Got: %d", status)
+		t.Fail()
+	}
+
+	status, token := request("PUT", "/latest/api/token", map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"}, "")
+
+	if status != http.StatusOK || token == "" {
+		t.Log("---------------")
+		t.Log("Did not get a session token")
+		t.Logf("Expected: %d", http.StatusOK)
+		t.Logf("Got: %d", status)
+		t.FailNow()
+	}
+
+	if status, _ := request("GET", "/latest/meta-data/iam/security-credentials/", nil, ""); status != http.StatusUnauthorized {
+		t.Log("---------------")
+		t.Log("Did not refuse credentials without a session token")
+		t.Logf("Expected: %d", http.StatusUnauthorized)
+		t.Logf("Got: %d", status)
+		t.Fail()
+	}
+
+	tokenHeader := map[string]string{"X-aws-ec2-metadata-token": token}
+
+	if _, body := request("GET", "/latest/meta-data/iam/security-credentials/", tokenHeader, ""); body != "vicuña" {
+		t.Log("---------------")
+		t.Log("Did not serve the role name")
+		t.Log("Expected: vicuña")
+		t.Logf("Got: %s", body)
+		t.Fail()
+	}
+
+	_, body := request("GET", "/latest/meta-data/iam/security-credentials/vicuña", tokenHeader, "")
+	creds := metadataCredentials{}
+	json.Unmarshal([]byte(body), &creds)
+
+	if creds.Code != "Success" || creds.AccessKeyId != "llama" || creds.Expiration != "2030-01-01T00:00:00Z" {
+		t.Log("---------------")
+		t.Log("Did not serve the role's credentials")
+		t.Log("Expected: llama credentials expiring at 2030-01-01T00:00:00Z")
+		t.Logf("Got: %+v", creds)
+		t.Fail()
+	}
+
+	if status, _ := request("PUT", "/yak/role", nil, "alpaca"); status != http.StatusUnauthorized {
+		t.Log("---------------")
+		t.Log("Switched roles without the control token")
+		t.Logf("Expected: %d", http.StatusUnauthorized)
+		t.Logf("Got: %d", status)
+		t.Fail()
+	}
+
+	controlHeader := map[string]string{"Authorization": "camelid"}
+
+	if status, _ := request("PUT", "/yak/role", controlHeader, "guanaco"); status != http.StatusBadGateway {
+		t.Log("---------------")
+		t.Log("Did not fail to switch to an unknown role")
+		t.Logf("Expected: %d", http.StatusBadGateway)
+		t.Logf("Got: %d", status)
+		t.Fail()
+	}
+
+	if status, _ := request("PUT", "/yak/role", controlHeader, "vicuña"); status != http.StatusConflict {
+		t.Log("---------------")
+		t.Log("Did not refuse to switch to a role that needs a login")
+		t.Logf("Expected: %d", http.StatusConflict)
+		t.Logf("Got: %d", status)
+		t.Fail()
+	}
+
+	if status, _ := request("PUT", "/yak/role", controlHeader, "alpaca\n"); status != http.StatusOK {
+		t.Log("---------------")
+		t.Log("Did not switch roles with the control token")
+		t.Logf("Expected: %d", http.StatusOK)
+		t.Logf("Got: %d", status)
+		t.Fail()
+	}
+
+	if _, body := request("GET", "/yak/role", controlHeader, ""); body != "alpaca\n" {
+		t.Log("---------------")
+		t.Log("Did not report the role it switched to")
+		t.Log("Expected: alpaca")
+		t.Logf("Got: %s", body)
+		t.Fail()
+	}
+
+	if _, body := request("GET", "/latest/meta-data/iam/security-credentials/", tokenHeader, ""); body != "alpaca" {
+		t.Log("---------------")
+		t.Log("Did not serve the role it switched to")
+		t.Log("Expected: alpaca")
+		t.Logf("Got: %s", body)
+		t.Fail()
+	}
+}
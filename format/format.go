@@ -5,38 +5,78 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/aws/aws-sdk-go/service/sts"
 
 	"github.com/redbubble/yak/aws"
 )
 
-var outputFormatters map[string]func(*sts.AssumeRoleWithSAMLOutput) (string, error) = map[string]func(*sts.AssumeRoleWithSAMLOutput) (string, error){
-	"json": func(creds *sts.AssumeRoleWithSAMLOutput) (string, error) {
+// The format the AWS CLI and SDKs expect from a credential_process
+type credentialProcessOutput struct {
+	Version         int
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      string
+}
+
+type outputFormatter func(creds *sts.AssumeRoleWithSAMLOutput, extraEnv map[string]string) (string, error)
+
+var outputFormatters map[string]outputFormatter = map[string]outputFormatter{
+	"json": func(creds *sts.AssumeRoleWithSAMLOutput, extraEnv map[string]string) (string, error) {
 		data, err := json.Marshal(creds.Credentials)
 
 		return string(append(data, '\n')), err
 	},
-	"env": func(creds *sts.AssumeRoleWithSAMLOutput) (string, error) {
+	"credential_process": func(creds *sts.AssumeRoleWithSAMLOutput, extraEnv map[string]string) (string, error) {
+		data, err := json.Marshal(credentialProcessOutput{
+			Version:         1,
+			AccessKeyId:     *creds.Credentials.AccessKeyId,
+			SecretAccessKey: *creds.Credentials.SecretAccessKey,
+			SessionToken:    *creds.Credentials.SessionToken,
+			Expiration:      creds.Credentials.Expiration.UTC().Format(time.RFC3339),
+		})
+
+		return string(append(data, '\n')), err
+	},
+	"env": func(creds *sts.AssumeRoleWithSAMLOutput, extraEnv map[string]string) (string, error) {
 		output := bytes.Buffer{}
 
-		var outputFormat string
-		if isPowerShell() {
-			outputFormat = "$env:%s = \"%s\"\n"
-		} else {
-			outputFormat = "export %s=%s\n"
+		for key, value := range aws.EnvironmentVariables(creds) {
+			output.WriteString(environmentVariable(key, value))
 		}
 
-		for key, value := range aws.EnvironmentVariables(creds) {
-			output.WriteString(fmt.Sprintf(outputFormat, key, value))
+		for key, value := range extraEnv {
+			output.WriteString(environmentVariable(key, value))
 		}
 
 		return output.String(), nil
 	},
 }
 
-func Credentials(format string, creds *sts.AssumeRoleWithSAMLOutput) (string, error) {
-	return outputFormatters[format](creds)
+func Credentials(format string, creds *sts.AssumeRoleWithSAMLOutput, extraEnv map[string]string) (string, error) {
+	return outputFormatters[format](creds, extraEnv)
+}
+
+// Environment formats variables to be sourced into the user's shell, like the
+// env output format does for credentials
+func Environment(env map[string]string) (string, error) {
+	output := bytes.Buffer{}
+
+	for key, value := range env {
+		output.WriteString(environmentVariable(key, value))
+	}
+
+	return output.String(), nil
+}
+
+func environmentVariable(key string, value string) string {
+	if isPowerShell() {
+		return fmt.Sprintf("$env:%s = \"%s\"\n", key, value)
+	}
+
+	return fmt.Sprintf("export %s=%s\n", key, value)
 }
 
 func ValidateOutputFormat(format string) error {
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/service/sts"
 )
@@ -15,10 +16,13 @@ var secretAccessKey string = "alpaca"
 var sessionToken string = "guanaco"
 var assumedRoleArn string = "arn:aws:iam::1234123123:role/sso-vicuña-role"
 
+var expiration time.Time = time.Date(2018, 4, 4, 23, 59, 54, 0, time.FixedZone("AEST", 10*60*60))
+
 var innerCreds sts.Credentials = sts.Credentials{
 	AccessKeyId:     &accessKeyId,
 	SecretAccessKey: &secretAccessKey,
 	SessionToken:    &sessionToken,
+	Expiration:      &expiration,
 }
 
 var creds sts.AssumeRoleWithSAMLOutput = sts.AssumeRoleWithSAMLOutput{
@@ -71,7 +75,7 @@ func TestDefaultEnvCredentials(t *testing.T) {
 			scenario.setUp()
 			defer scenario.tearDown()
 
-			text, err := Credentials("env", &creds)
+			text, err := Credentials("env", &creds, nil)
 
 			if err != nil {
 				t.Log("---------------")
@@ -105,7 +109,7 @@ func TestDefaultEnvCredentials(t *testing.T) {
 }
 
 func TestJsonCredentials(t *testing.T) {
-	jsonData, err := Credentials("json", &creds)
+	jsonData, err := Credentials("json", &creds, nil)
 
 	if err != nil {
 		t.Log("---------------")
@@ -136,6 +140,27 @@ func TestJsonCredentials(t *testing.T) {
 	}
 }
 
+func TestCredentialProcessCredentials(t *testing.T) {
+	jsonData, err := Credentials("credential_process", &creds, nil)
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("Got an error formatting as \"credential_process\"")
+		t.Logf("Error: %v", err)
+		t.Fail()
+	}
+
+	expected := `{"Version":1,"AccessKeyId":"llama","SecretAccessKey":"alpaca","SessionToken":"guanaco","Expiration":"2018-04-04T13:59:54Z"}` + "\n"
+
+	if jsonData != expected {
+		t.Log("---------------")
+		t.Log("Failed to format credentials as \"credential_process\"")
+		t.Logf("Expected content: %s", expected)
+		t.Logf("Actual content: %s", jsonData)
+		t.Fail()
+	}
+}
+
 func TestValidateOutputFormat(t *testing.T) {
 	if err := ValidateOutputFormat("env"); err != nil {
 		t.Log("---------------")
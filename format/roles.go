@@ -0,0 +1,90 @@
+package format
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RoleRecord is what machine-readable role listings say about each role
+type RoleRecord struct {
+	RoleArn           string     `json:"role_arn"`
+	PrincipalArn      string     `json:"principal_arn"`
+	AccountId         string     `json:"account_id"`
+	AccountName       string     `json:"account_name"`
+	RoleName          string     `json:"role_name"`
+	Aliases           []string   `json:"aliases"`
+	CredentialsCached bool       `json:"credentials_cached"`
+	Expiration        *time.Time `json:"expiration"`
+}
+
+var roleRecordColumns = []string{
+	"role_arn",
+	"principal_arn",
+	"account_id",
+	"account_name",
+	"role_name",
+	"aliases",
+	"credentials_cached",
+	"expiration",
+}
+
+type roleFormatter func(records []RoleRecord) (string, error)
+
+var roleFormatters map[string]roleFormatter = map[string]roleFormatter{
+	"json": func(records []RoleRecord) (string, error) {
+		data, err := json.MarshalIndent(records, "", "  ")
+
+		return string(append(data, '\n')), err
+	},
+	"csv": func(records []RoleRecord) (string, error) {
+		output := bytes.Buffer{}
+		writer := csv.NewWriter(&output)
+
+		writer.Write(roleRecordColumns)
+
+		for _, record := range records {
+			expiration := ""
+			if record.Expiration != nil {
+				expiration = record.Expiration.UTC().Format(time.RFC3339)
+			}
+
+			writer.Write([]string{
+				record.RoleArn,
+				record.PrincipalArn,
+				record.AccountId,
+				record.AccountName,
+				record.RoleName,
+				strings.Join(record.Aliases, " "),
+				strconv.FormatBool(record.CredentialsCached),
+				expiration,
+			})
+		}
+
+		writer.Flush()
+
+		return output.String(), writer.Error()
+	},
+}
+
+func Roles(format string, records []RoleRecord) (string, error) {
+	return roleFormatters[format](records)
+}
+
+// ValidateRoleListFormat checks a format for --list-roles; 'text' is the
+// usual listing, which isn't ours to print
+func ValidateRoleListFormat(format string) error {
+	if format == "text" {
+		return nil
+	}
+
+	if _, ok := roleFormatters[format]; ok {
+		return nil
+	}
+
+	return fmt.Errorf("Invalid role list format '%s' specified. Valid formats: [text json csv]", format)
+}
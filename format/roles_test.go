@@ -0,0 +1,105 @@
+package format
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var roleRecords = []RoleRecord{
+	{
+		RoleArn:           "arn:aws:iam::123456789012:role/admin",
+		PrincipalArn:      "arn:aws:iam::123456789012:saml-provider/okta",
+		AccountId:         "123456789012",
+		AccountName:       "production",
+		RoleName:          "admin",
+		Aliases:           []string{"ops", "prod"},
+		CredentialsCached: true,
+		Expiration:        &expiration,
+	},
+	{
+		RoleArn:      "arn:aws:iam::123456789012:role/readonly",
+		PrincipalArn: "arn:aws:iam::123456789012:saml-provider/okta",
+		AccountId:    "123456789012",
+		RoleName:     "readonly",
+		Aliases:      []string{},
+	},
+}
+
+func TestJsonRoles(t *testing.T) {
+	output, err := Roles("json", roleRecords)
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("Got an error formatting roles as \"json\"")
+		t.Logf("Error: %v", err)
+		t.FailNow()
+	}
+
+	parsed := []map[string]interface{}{}
+
+	if err := json.Unmarshal([]byte(output), &parsed); err != nil {
+		t.Log("---------------")
+		t.Log("Roles formatted as \"json\" were not valid JSON")
+		t.Logf("Error: %v", err)
+		t.FailNow()
+	}
+
+	if len(parsed) != 2 || parsed[0]["role_arn"] != roleRecords[0].RoleArn || parsed[0]["credentials_cached"] != true {
+		t.Log("---------------")
+		t.Log("Roles were not listed as an array of records")
+		t.Logf("Expected: %+v", roleRecords)
+		t.Logf("Got: %v", parsed)
+		t.Fail()
+	}
+
+	if parsed[1]["expiration"] != nil {
+		t.Log("---------------")
+		t.Log("A role without cached credentials had an expiration")
+		t.Log("Expected: null")
+		t.Logf("Got: %v", parsed[1]["expiration"])
+		t.Fail()
+	}
+}
+
+func TestCsvRoles(t *testing.T) {
+	output, err := Roles("csv", roleRecords)
+
+	if err != nil {
+		t.Log("---------------")
+		t.Log("Got an error formatting roles as \"csv\"")
+		t.Logf("Error: %v", err)
+		t.FailNow()
+	}
+
+	expected := []string{
+		"role_arn,principal_arn,account_id,account_name,role_name,aliases,credentials_cached,expiration",
+		"arn:aws:iam::123456789012:role/admin,arn:aws:iam::123456789012:saml-provider/okta,123456789012,production,admin,ops prod,true,2018-04-04T13:59:54Z",
+		"arn:aws:iam::123456789012:role/readonly,arn:aws:iam::123456789012:saml-provider/okta,123456789012,,readonly,,false,",
+	}
+
+	if lines := strings.Split(strings.TrimSpace(output), "\n"); strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Log("---------------")
+		t.Log("Roles were not listed one per row after a header, with times in UTC")
+		t.Logf("Expected:\n%s", strings.Join(expected, "\n"))
+		t.Logf("Got:\n%s", output)
+		t.Fail()
+	}
+}
+
+func TestValidateRoleListFormat(t *testing.T) {
+	for _, valid := range []string{"text", "json", "csv"} {
+		if err := ValidateRoleListFormat(valid); err != nil {
+			t.Log("---------------")
+			t.Logf("Got an error from ValidateRoleListFormat when requesting \"%s\"", valid)
+			t.Logf("Error: %v", err)
+			t.Fail()
+		}
+	}
+
+	if err := ValidateRoleListFormat("env"); err == nil {
+		t.Log("---------------")
+		t.Log("ValidateRoleListFormat accepted \"env\"")
+		t.Fail()
+	}
+}
@@ -0,0 +1,169 @@
+package formpost
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+
+	"github.com/redbubble/yak/saml"
+)
+
+type UserData struct {
+	Username string
+	Password string
+}
+
+type FieldNames struct {
+	Username string
+	Password string
+}
+
+type loginForm struct {
+	Action string
+	Values url.Values
+}
+
+func Login(loginHref string, fields FieldNames, userData UserData) (string, error) {
+	jar, err := cookiejar.New(nil)
+
+	if err != nil {
+		return "", err
+	}
+
+	client := http.Client{
+		Jar: jar,
+	}
+
+	resp, err := client.Get(loginHref)
+
+	if err != nil {
+		return "", err
+	} else if resp.StatusCode >= 300 {
+		return "", errors.New("Could not load login page (" + resp.Status + ")")
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if err != nil {
+		return "", err
+	}
+
+	form, err := findLoginForm(body, fields.Password)
+
+	if err != nil {
+		return "", err
+	}
+
+	actionUrl, err := resp.Request.URL.Parse(form.Action)
+
+	if err != nil {
+		return "", err
+	}
+
+	form.Values.Set(fields.Username, userData.Username)
+	form.Values.Set(fields.Password, userData.Password)
+
+	log.WithField("url", actionUrl.String()).Debug("formpost.go: Submitting login form")
+	resp, err = client.PostForm(actionUrl.String(), form.Values)
+
+	if err != nil {
+		return "", err
+	} else if resp.StatusCode == 401 || resp.StatusCode == 403 {
+		return "", errors.New("Unauthorised (" + resp.Status + ")")
+	} else if resp.StatusCode >= 300 {
+		return "", errors.New("Could not get SAML payload (" + resp.Status + ")")
+	}
+
+	defer resp.Body.Close()
+	body, err = ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return "", err
+	}
+
+	data, err := saml.ExtractPayload(body)
+
+	if err != nil {
+		return "", fmt.Errorf("%w; check your username and password", err)
+	}
+
+	samlPayload, err := base64.StdEncoding.DecodeString(data)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(samlPayload), nil
+}
+
+// findLoginForm returns the first form on the page containing the password
+// field, along with any values (hidden CSRF tokens and the like) it carries.
+func findLoginForm(htmlDocument []byte, passwordField string) (loginForm, error) {
+	tokeniser := html.NewTokenizer(bytes.NewBuffer(htmlDocument))
+
+	var form loginForm
+	inForm := false
+	hasPassword := false
+
+	for {
+		tokenType := tokeniser.Next()
+
+		if tokenType == html.ErrorToken {
+			return loginForm{}, fmt.Errorf("No login form with a '%s' field found on the login page", passwordField)
+		}
+
+		token := tokeniser.Token()
+
+		switch {
+		case tokenType == html.StartTagToken && token.Data == "form":
+			form = loginForm{Values: url.Values{}}
+			inForm = true
+			hasPassword = false
+
+			for _, attribute := range token.Attr {
+				if attribute.Key == "action" {
+					form.Action = strings.TrimSpace(attribute.Val)
+				}
+			}
+		case tokenType == html.EndTagToken && token.Data == "form":
+			if inForm && hasPassword {
+				return form, nil
+			}
+
+			inForm = false
+		case inForm && (tokenType == html.StartTagToken || tokenType == html.SelfClosingTagToken) && token.Data == "input":
+			var inputName string
+			var inputValue string
+
+			for _, attribute := range token.Attr {
+				if attribute.Key == "name" {
+					inputName = attribute.Val
+				}
+
+				if attribute.Key == "value" {
+					inputValue = attribute.Val
+				}
+			}
+
+			if inputName == "" {
+				continue
+			}
+
+			if inputName == passwordField {
+				hasPassword = true
+			}
+
+			form.Values.Set(inputName, inputValue)
+		}
+	}
+}
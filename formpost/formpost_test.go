@@ -0,0 +1,59 @@
+package formpost
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogin(t *testing.T) {
+	assertion := "<saml2p:Response></saml2p:Response>"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "alpaca"})
+		fmt.Fprint(w, `<html><body>
+                         <form id="search" action="/search"><input name="q" /></form>
+                         <form id="login" method="post" action="/authenticate?client=aws">
+                           <input type="hidden" name="csrf" value="llama" />
+                           <input type="text" name="user" />
+                           <input type="password" name="pass" />
+                         </form>
+                       </body></html>`)
+	})
+	mux.HandleFunc("/authenticate", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+
+		if err != nil || cookie.Value != "alpaca" || r.URL.Query().Get("client") != "aws" ||
+			r.FormValue("csrf") != "llama" || r.FormValue("user") != "vicuña" || r.FormValue("pass") != "guanaco" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		fmt.Fprintf(w, `<form><input type="hidden" name="SAMLResponse" value="%s" /></form>`, base64.StdEncoding.EncodeToString([]byte(assertion)))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fields := FieldNames{Username: "user", Password: "pass"}
+	payload, err := Login(server.URL+"/login", fields, UserData{Username: "vicuña", Password: "guanaco"})
+
+	if err != nil || payload != assertion {
+		t.Log("---------------")
+		t.Log("Did not log in and retrieve the SAML assertion")
+		t.Logf("Expected: %s", assertion)
+		t.Logf("Got: %s (error: %v)", payload, err)
+		t.Fail()
+	}
+
+	_, err = Login(server.URL+"/login", fields, UserData{Username: "vicuña", Password: "wrong"})
+
+	if err == nil {
+		t.Log("---------------")
+		t.Log("Logged in with the wrong password")
+		t.Fail()
+	}
+}
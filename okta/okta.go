@@ -15,7 +15,8 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/net/html"
+
+	"github.com/redbubble/yak/saml"
 )
 
 type UserData struct {
@@ -293,7 +294,7 @@ func AwsSamlLogin(oktaHref string, samlHref string, oktasession OktaSession) (st
 		return "", err
 	}
 
-	data, err := extractSamlPayload(body)
+	data, err := saml.ExtractPayload(body)
 
 	if err != nil {
 		return "", err
@@ -335,43 +336,6 @@ func makeRequest(url string, body io.Reader) ([]byte, int, error) {
 	return responseBody, YAK_STATUS_OK, err
 }
 
-func extractSamlPayload(htmlDocument []byte) (string, error) {
-	tokeniser := html.NewTokenizer(bytes.NewBuffer(htmlDocument))
-
-	var data string
-
-	for {
-		tokeniser.Next()
-		token := tokeniser.Token()
-
-		if token.Type == html.ErrorToken {
-			return "", errors.New("No SAML payload found in response from Okta")
-		}
-
-		if (token.Type == html.SelfClosingTagToken || token.Type == html.StartTagToken) && token.Data == "input" {
-			var inputName string
-			var inputValue string
-
-			for _, attribute := range token.Attr {
-				if attribute.Key == "name" {
-					inputName = attribute.Val
-				}
-
-				if attribute.Key == "value" {
-					inputValue = attribute.Val
-				}
-			}
-
-			if inputName == "SAMLResponse" {
-				data = inputValue
-				break
-			}
-		}
-	}
-
-	return data, nil
-}
-
 func TotpFactorName(key string) string {
 	switch key {
 	case "GOOGLE":
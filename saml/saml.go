@@ -1,11 +1,17 @@
 package saml
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
 )
 
 type samlResponse struct {
@@ -14,7 +20,7 @@ type samlResponse struct {
 
 type samlAssertion struct {
 	Attributes []samlAssertionAttribute `xml:"AttributeStatement>Attribute"`
-	Conditions samlAssertionConditions  `xml:Conditions`
+	Conditions samlAssertionConditions  `xml:"Conditions"`
 }
 
 type samlAssertionConditions struct {
@@ -35,6 +41,9 @@ type LoginRole struct {
 type LoginData struct {
 	Roles     []LoginRole
 	Assertion string
+	// NotOnOrAfter is when AWS will stop accepting the assertion, or zero if
+	// it didn't say
+	NotOnOrAfter time.Time
 }
 
 func ParseResponse(saml string) (samlResponse, error) {
@@ -44,20 +53,47 @@ func ParseResponse(saml string) (samlResponse, error) {
 	return response, err
 }
 
+// DecodeAssertion accepts a SAML response either as raw XML or as the base64
+// blob an IdP posts to AWS, and returns the XML.
+func DecodeAssertion(data []byte) (string, error) {
+	trimmed := bytes.TrimSpace(data)
+
+	if len(trimmed) == 0 {
+		return "", errors.New("SAML assertion is empty")
+	}
+
+	if trimmed[0] == '<' {
+		return string(trimmed), nil
+	}
+
+	encoded := strings.Join(strings.Fields(string(trimmed)), "")
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return "", fmt.Errorf("SAML assertion is neither XML nor base64: %w", err)
+	}
+
+	return string(decoded), nil
+}
+
 func CreateLoginData(response samlResponse, payload string) LoginData {
 	login := LoginData{
-		Roles:     []LoginRole{},
-		Assertion: base64.StdEncoding.EncodeToString([]byte(payload)),
+		Roles:        []LoginRole{},
+		Assertion:    base64.StdEncoding.EncodeToString([]byte(payload)),
+		NotOnOrAfter: response.Assertion.Conditions.NotOnOrAfter,
 	}
 
 	for _, attribute := range response.Assertion.Attributes {
 		if attribute.Name == "https://aws.amazon.com/SAML/Attributes/Role" {
 			for _, value := range attribute.Values {
-				role, ok := CreateLoginRole(value)
+				role, err := ParseLoginRole(value)
 
-				if ok {
-					login.Roles = append(login.Roles, role)
+				if err != nil {
+					log.Infof("Ignoring malformed AWS role attribute %q: %v", value, err)
+					continue
 				}
+
+				login.Roles = append(login.Roles, role)
 			}
 		}
 	}
@@ -66,16 +102,75 @@ func CreateLoginData(response samlResponse, payload string) LoginData {
 }
 
 func CreateLoginRole(roleData string) (LoginRole, bool) {
-	parts := strings.Split(roleData, ",")
-
-	if len(parts) == 2 {
-		return LoginRole{
-			RoleArn:      parts[1],
-			PrincipalArn: parts[0],
-		}, true
-	} else {
-		return LoginRole{}, false
+	role, err := ParseLoginRole(roleData)
+
+	return role, err == nil
+}
+
+// ParseLoginRole accepts the "principal,role" pairs AWS expects in the Role
+// attribute, but doesn't rely on their order: IdPs differ on that, and some
+// pad the values with whitespace or leave a trailing comma.
+func ParseLoginRole(roleData string) (LoginRole, error) {
+	parts := []string{}
+
+	for _, part := range strings.Split(roleData, ",") {
+		part = strings.TrimSpace(part)
+
+		if part != "" {
+			parts = append(parts, part)
+		}
 	}
+
+	if len(parts) != 2 {
+		return LoginRole{}, fmt.Errorf("expected a principal ARN and a role ARN, got %d value(s)", len(parts))
+	}
+
+	role := LoginRole{}
+
+	for _, part := range parts {
+		resourceType, err := iamResourceType(part)
+
+		if err != nil {
+			return LoginRole{}, err
+		}
+
+		switch resourceType {
+		case "saml-provider":
+			if role.PrincipalArn != "" {
+				return LoginRole{}, fmt.Errorf("found more than one SAML provider ARN")
+			}
+			role.PrincipalArn = part
+		case "role":
+			if role.RoleArn != "" {
+				return LoginRole{}, fmt.Errorf("found more than one role ARN")
+			}
+			role.RoleArn = part
+		default:
+			return LoginRole{}, fmt.Errorf("%s is neither a SAML provider nor a role ARN", part)
+		}
+	}
+
+	return role, nil
+}
+
+func iamResourceType(value string) (string, error) {
+	parsed, err := arn.Parse(value)
+
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", value, err)
+	}
+
+	if parsed.Service != "iam" {
+		return "", fmt.Errorf("%s is not an IAM ARN", value)
+	}
+
+	resourceType, _, found := strings.Cut(parsed.Resource, "/")
+
+	if !found {
+		return "", fmt.Errorf("%s has no resource name", value)
+	}
+
+	return resourceType, nil
 }
 
 func SerialiseLoginRole(role LoginRole) string {
@@ -91,3 +186,35 @@ func (login LoginData) GetLoginRole(roleArn string) (LoginRole, error) {
 
 	return LoginRole{}, fmt.Errorf("ARN %s is not in the list of available roles for this user", roleArn)
 }
+
+func ExtractPayload(htmlDocument []byte) (string, error) {
+	tokeniser := html.NewTokenizer(bytes.NewBuffer(htmlDocument))
+
+	for {
+		tokeniser.Next()
+		token := tokeniser.Token()
+
+		if token.Type == html.ErrorToken {
+			return "", errors.New("No SAML payload found in response from identity provider")
+		}
+
+		if (token.Type == html.SelfClosingTagToken || token.Type == html.StartTagToken) && token.Data == "input" {
+			var inputName string
+			var inputValue string
+
+			for _, attribute := range token.Attr {
+				if attribute.Key == "name" {
+					inputName = attribute.Val
+				}
+
+				if attribute.Key == "value" {
+					inputValue = attribute.Val
+				}
+			}
+
+			if inputName == "SAMLResponse" {
+				return inputValue, nil
+			}
+		}
+	}
+}
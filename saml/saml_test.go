@@ -107,14 +107,16 @@ func TestCreateLoginData(t *testing.T) {
 	roleAttribute := samlAssertionAttribute{
 		Name: "https://aws.amazon.com/SAML/Attributes/Role",
 		Values: []string{
-			"cheese,manchego",
-			"cheese,reypenaer",
+			"arn:aws:iam::123456789012:saml-provider/cheese,arn:aws:iam::123456789012:role/manchego",
+			"arn:aws:iam::123456789012:saml-provider/cheese,arn:aws:iam::123456789012:role/reypenaer",
 		},
 	}
 
+	notOnOrAfter := time.Date(2018, 4, 4, 23, 59, 54, 0, time.UTC)
+
 	response := samlResponse{
 		Assertion: samlAssertion{
-			Conditions: samlAssertionConditions{},
+			Conditions: samlAssertionConditions{NotOnOrAfter: notOnOrAfter},
 			Attributes: []samlAssertionAttribute{
 				roleAttribute,
 			},
@@ -123,6 +125,14 @@ func TestCreateLoginData(t *testing.T) {
 
 	subject := CreateLoginData(response, payload)
 
+	if !subject.NotOnOrAfter.Equal(notOnOrAfter) {
+		t.Log("---------------")
+		t.Log("Did not keep the assertion's NotOnOrAfter condition")
+		t.Logf("Expected: %s", notOnOrAfter)
+		t.Logf("Got: %s", subject.NotOnOrAfter)
+		t.Fail()
+	}
+
 	if subject.Assertion != encodedPayload {
 		t.Log("---------------")
 		t.Log("Did not correctly encode the SAML assertion")
@@ -153,6 +163,86 @@ func TestCreateLoginData(t *testing.T) {
 	}
 }
 
+func TestCreateLoginDataSkipsMalformedRoles(t *testing.T) {
+	response := samlResponse{
+		Assertion: samlAssertion{
+			Attributes: []samlAssertionAttribute{
+				{
+					Name: "https://aws.amazon.com/SAML/Attributes/Role",
+					Values: []string{
+						"arn:aws:iam::123456789012:saml-provider/cheese,arn:aws:iam::123456789012:role/manchego",
+						"cheese,reypenaer",
+					},
+				},
+			},
+		},
+	}
+
+	subject := CreateLoginData(response, "abloboftext")
+
+	if len(subject.Roles) != 1 || subject.Roles[0].RoleArn != "arn:aws:iam::123456789012:role/manchego" {
+		t.Log("---------------")
+		t.Log("Did not skip the malformed role")
+		t.Logf("Got: %v", subject.Roles)
+		t.Fail()
+	}
+}
+
+func TestParseLoginRole(t *testing.T) {
+	principal := "arn:aws:iam::123456789012:saml-provider/okta"
+	role := "arn:aws:iam::123456789012:role/llama"
+
+	govPrincipal := "arn:aws-us-gov:iam::123456789012:saml-provider/okta"
+	govRole := "arn:aws-us-gov:iam::123456789012:role/llama"
+
+	valid := map[string]LoginRole{
+		principal + "," + role:               {RoleArn: role, PrincipalArn: principal},
+		role + "," + principal:               {RoleArn: role, PrincipalArn: principal},
+		" " + principal + " , " + role + " ": {RoleArn: role, PrincipalArn: principal},
+		principal + "," + role + ",":         {RoleArn: role, PrincipalArn: principal},
+		govPrincipal + "," + govRole:         {RoleArn: govRole, PrincipalArn: govPrincipal},
+	}
+
+	for value, expected := range valid {
+		subject, err := ParseLoginRole(value)
+
+		if err != nil {
+			t.Log("---------------")
+			t.Logf("Failed to parse role attribute %q", value)
+			t.Logf("Error: %v", err)
+			t.Fail()
+			continue
+		}
+
+		if subject != expected {
+			t.Log("---------------")
+			t.Logf("Parsed role attribute %q incorrectly", value)
+			t.Logf("Expected: %s, %s", expected.PrincipalArn, expected.RoleArn)
+			t.Logf("Got: %s, %s", subject.PrincipalArn, subject.RoleArn)
+			t.Fail()
+		}
+	}
+
+	invalid := []string{
+		"",
+		principal,
+		principal + "," + principal,
+		role + "," + role,
+		principal + "," + role + "," + role,
+		"cheese,manchego",
+		principal + ",arn:aws:s3:::llama/bucket",
+		principal + ",arn:aws:iam::123456789012:user/llama",
+	}
+
+	for _, value := range invalid {
+		if _, err := ParseLoginRole(value); err == nil {
+			t.Log("---------------")
+			t.Logf("Parsed malformed role attribute %q without error", value)
+			t.Fail()
+		}
+	}
+}
+
 func TestGetLoginRole(t *testing.T) {
 	expectedRole := LoginRole{
 		RoleArn:      "aws:arn:llama",
@@ -191,3 +281,60 @@ func TestGetLoginRole(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestExtractPayload(t *testing.T) {
+	document := `<html><body><form method="post" action="https://signin.aws.amazon.com/saml">
+                   <input type="hidden" name="RelayState" value="" />
+                   <input type="hidden" name="SAMLResponse" value="bGxhbWE=" />
+                 </form></body></html>`
+
+	payload, err := ExtractPayload([]byte(document))
+
+	if err != nil || payload != "bGxhbWE=" {
+		t.Log("---------------")
+		t.Log("Did not extract the SAML payload from the document")
+		t.Logf("Expected: %s", "bGxhbWE=")
+		t.Logf("Got: %s (error: %v)", payload, err)
+		t.Fail()
+	}
+
+	_, err = ExtractPayload([]byte(`<html><body><form><input name="password" /></form></body></html>`))
+
+	if err == nil {
+		t.Log("---------------")
+		t.Log("Extracted a SAML payload from a document without one")
+		t.Fail()
+	}
+}
+
+func TestDecodeAssertion(t *testing.T) {
+	xml := "<saml2p:Response></saml2p:Response>"
+	encoded := base64.StdEncoding.EncodeToString([]byte(xml))
+
+	scenarios := []string{
+		xml,
+		"\n  " + xml + "\n",
+		encoded,
+		encoded[:20] + "\n" + encoded[20:] + "\n",
+	}
+
+	for _, scenario := range scenarios {
+		subject, err := DecodeAssertion([]byte(scenario))
+
+		if err != nil || subject != xml {
+			t.Log("---------------")
+			t.Logf("Did not decode assertion %q", scenario)
+			t.Logf("Expected: %s", xml)
+			t.Logf("Got: %s (error: %v)", subject, err)
+			t.Fail()
+		}
+	}
+
+	for _, scenario := range []string{"", "   ", "not base64!"} {
+		if _, err := DecodeAssertion([]byte(scenario)); err == nil {
+			t.Log("---------------")
+			t.Logf("Decoded invalid assertion %q without error", scenario)
+			t.Fail()
+		}
+	}
+}